@@ -0,0 +1,222 @@
+package fx
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyTolerance 处理耗时超过基准耗时的倍数后认为下游过载
+	latencyTolerance = 2
+	// backoffRatio 下游过载时并发数缩小的比例
+	backoffRatio = 0.9
+	// latencyWindow 计算基准耗时使用的最近样本数，使基准能跟上下游耗时的长期变化，也不会被个别过快的样本固定住
+	latencyWindow = 64
+	// baselinePercentile 基准耗时取最近样本中的分位数
+	baselinePercentile = 0.1
+	// throughputTolerance 增加并发数后吞吐量低于之前的比例时认为增加并发没有收益
+	throughputTolerance = 0.95
+)
+
+// aimdLimiter 按AIMD方式调整并发数：处理耗时明显高于基准耗时时按比例缩小；
+// 每完成并发数个元素为一轮，一轮中worker曾经用满且吞吐量没有比上一轮下降时加1，吞吐量下降时减1，
+// 并发数始终在[min, max]之间
+type aimdLimiter struct {
+	lock       sync.Mutex
+	min        int
+	max        int
+	limit      float64
+	inflight   int
+	samples    []time.Duration
+	next       int
+	epochStart time.Time
+	completed  int
+	saturated  bool
+	throughput float64
+	ready      chan struct{}
+}
+
+// WithAdaptiveWorkers 使Walk、Map等操作的并发数根据处理耗时和吞吐量在min和max之间自动调整，
+// 从min开始，下游耗时稳定且吞吐量没有下降时逐步增加，耗时明显变长或吞吐量下降时减少；与Ordered同时使用时固定为max
+func WithAdaptiveWorkers(min, max int) Option {
+	return func(opts *rxOptions) {
+		if min < minWorkers {
+			min = minWorkers
+		}
+		if max < min {
+			max = min
+		}
+
+		opts.adaptiveMin = min
+		opts.adaptiveMax = max
+		opts.workers = max
+	}
+}
+
+func newAimdLimiter(min, max int) *aimdLimiter {
+	return &aimdLimiter{
+		min:   min,
+		max:   max,
+		limit: float64(min),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// current 返回当前的并发数
+func (l *aimdLimiter) current() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return int(l.limit)
+}
+
+// acquire 在正在执行的元素数少于当前并发数时占用一个worker，ctxDone或stateDone关闭时返回false
+func (l *aimdLimiter) acquire(ctxDone, stateDone <-chan struct{}) bool {
+	for {
+		l.lock.Lock()
+		if l.inflight < int(l.limit) {
+			l.inflight++
+			l.lock.Unlock()
+			return true
+		}
+		l.lock.Unlock()
+
+		select {
+		case <-l.ready:
+		case <-ctxDone:
+			return false
+		case <-stateDone:
+			return false
+		}
+	}
+}
+
+// release 释放一个worker，并根据该元素在[start, end)内的处理耗时和本轮的吞吐量调整并发数
+func (l *aimdLimiter) release(start, end time.Time) {
+	l.lock.Lock()
+	if l.inflight >= int(l.limit) {
+		l.saturated = true
+	}
+	l.inflight--
+	if l.epochStart.IsZero() {
+		l.epochStart = start
+	}
+	l.completed++
+
+	latency := end.Sub(start)
+	if latency > l.baseline(latency)*latencyTolerance {
+		l.setLimit(l.limit * backoffRatio)
+		// 缩小后的吞吐量不能与之前比较，重新开始一轮
+		l.throughput = 0
+		l.resetEpoch(end)
+	} else if l.completed >= int(l.limit) {
+		l.adjust(end)
+	}
+	l.lock.Unlock()
+
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+// adjust 在一轮结束时根据吞吐量调整并发数
+func (l *aimdLimiter) adjust(end time.Time) {
+	var throughput float64
+	if elapsed := end.Sub(l.epochStart); elapsed > 0 {
+		throughput = float64(l.completed) / elapsed.Seconds()
+	}
+
+	if l.saturated {
+		if l.throughput == 0 || throughput >= l.throughput*throughputTolerance {
+			l.setLimit(l.limit + 1)
+		} else {
+			l.setLimit(l.limit - 1)
+		}
+	}
+	l.throughput = throughput
+	l.resetEpoch(end)
+}
+
+// baseline 记录latency，并返回最近样本中的低分位耗时作为基准耗时
+func (l *aimdLimiter) baseline(latency time.Duration) time.Duration {
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, latency)
+	} else {
+		l.samples[l.next] = latency
+		l.next = (l.next + 1) % latencyWindow
+	}
+
+	sorted := make([]time.Duration, len(l.samples))
+	copy(sorted, l.samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	return sorted[int(float64(len(sorted))*baselinePercentile)]
+}
+
+// resetEpoch 从now开始新的一轮
+func (l *aimdLimiter) resetEpoch(now time.Time) {
+	l.epochStart = now
+	l.completed = 0
+	l.saturated = false
+}
+
+// setLimit 将并发数设置为limit，并限制在[min, max]之间
+func (l *aimdLimiter) setLimit(limit float64) {
+	if limit < float64(l.min) {
+		limit = float64(l.min)
+	}
+	if limit > float64(l.max) {
+		limit = float64(l.max)
+	}
+	l.limit = limit
+}
+
+// walkAdaptive 与walkLimited相同，但并发数由limiter根据处理耗时动态调整
+func (s Stream) walkAdaptive(fn WalkFunc, option *rxOptions, limiter *aimdLimiter, st *stage) Stream {
+	pipe := make(chan any, option.workers)
+
+	go func() {
+		var wg sync.WaitGroup
+
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+
+			if !limiter.acquire(s.ctx.Done(), s.state.done) {
+				break
+			}
+
+			// important, used in another goroutine
+			val := item
+			wg.Add(1)
+
+			// better to safely run caller defined method
+			go func() {
+				start := option.clock.Now()
+				defer func() {
+					wg.Done()
+					limiter.release(start, option.clock.Now())
+				}()
+				defer s.state.recover()
+
+				fn(val, pipe)
+			}()
+		}
+
+		// 流被取消时上游可能仍在写入，清空以免上游阻塞
+		s.release()
+		// 流被取消后下游不再读取，清空pipe以免worker阻塞在写入上
+		stop := s.drainCancelled(pipe)
+		wg.Wait()
+		close(pipe)
+		stop()
+	}()
+
+	return s.deriveStage(pipe, st)
+}
@@ -0,0 +1,127 @@
+package fx
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAimdLimiter(t *testing.T) {
+	limiter := newAimdLimiter(2, 8)
+	never := make(chan struct{})
+	round := aimdRound(t, limiter)
+
+	for i := 0; i < 10; i++ {
+		round(10 * time.Millisecond)
+	}
+	if limit := limiter.current(); limit != 8 {
+		t.Fatalf("expect limit grows to 8, got %d", limit)
+	}
+
+	for i := 0; i < 5; i++ {
+		round(100 * time.Millisecond)
+	}
+	if limit := limiter.current(); limit != 2 {
+		t.Fatalf("expect limit shrinks to 2, got %d", limit)
+	}
+
+	// 已用满时acquire阻塞，直到done关闭
+	done := make(chan struct{})
+	close(done)
+	limiter.acquire(never, never)
+	limiter.acquire(never, never)
+	if limiter.acquire(never, done) {
+		t.Fatal("expect acquire fails when limit is reached and stream is done")
+	}
+}
+
+func TestAimdLimiterOutlier(t *testing.T) {
+	limiter := newAimdLimiter(2, 8)
+	round := aimdRound(t, limiter)
+
+	// 个别过快的样本只影响最近的少量样本，不会使之后正常的耗时一直被当作过载
+	never := make(chan struct{})
+	start := time.Unix(0, 0)
+	limiter.acquire(never, never)
+	limiter.release(start, start.Add(time.Microsecond))
+	for i := 0; i < 15; i++ {
+		round(10 * time.Millisecond)
+	}
+	if limit := limiter.current(); limit != 8 {
+		t.Fatalf("expect limit grows to 8 after a fast outlier, got %d", limit)
+	}
+}
+
+func TestAimdLimiterThroughput(t *testing.T) {
+	limiter := newAimdLimiter(2, 8)
+	start := time.Unix(0, 0)
+	never := make(chan struct{})
+
+	// 耗时不变但每轮的间隔越来越长，吞吐量下降时不再增加并发数
+	for i := 0; i < 4; i++ {
+		n := limiter.current()
+		for j := 0; j < n; j++ {
+			limiter.acquire(never, never)
+		}
+		for j := 0; j < n; j++ {
+			limiter.release(start, start.Add(10*time.Millisecond))
+		}
+		start = start.Add(time.Duration(i+1) * 100 * time.Millisecond)
+	}
+	if limit := limiter.current(); limit > 3 {
+		t.Fatalf("expect limit stays low when throughput drops, got %d", limit)
+	}
+}
+
+func TestWalkAdaptiveWorkers(t *testing.T) {
+	var running, peak int32
+	count, err := Range(func() <-chan any {
+		source := make(chan any)
+		go func() {
+			defer close(source)
+			for i := 0; i < 200; i++ {
+				source <- i
+			}
+		}()
+		return source
+	}()).Walk(func(item any, pipe chan<- any) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+
+		time.Sleep(2 * time.Millisecond)
+		pipe <- item
+	}, WithAdaptiveWorkers(2, 6)).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 200 {
+		t.Fatalf("expect 200, got %d", count)
+	}
+	if peak > 6 || peak <= 2 {
+		t.Fatalf("expect concurrency between 3 and 6, got %d", peak)
+	}
+}
+
+// aimdRound 返回一个函数，每次调用占满limiter当前的并发数，再以相同的耗时依次释放
+func aimdRound(t *testing.T, limiter *aimdLimiter) func(latency time.Duration) {
+	now := time.Unix(0, 0)
+	never := make(chan struct{})
+	return func(latency time.Duration) {
+		n := limiter.current()
+		for i := 0; i < n; i++ {
+			if !limiter.acquire(never, never) {
+				t.Fatal("acquire failed")
+			}
+		}
+		for i := 0; i < n; i++ {
+			limiter.release(now, now.Add(latency))
+		}
+		now = now.Add(latency)
+	}
+}
@@ -0,0 +1,205 @@
+package fx
+
+import "just4play/util/lang"
+
+type (
+	// Aggregator 定义了对一组元素的增量聚合，Aggregate对每个key只保存一个累加器，而不保存元素本身
+	Aggregator interface {
+		// New 返回一个新的累加器
+		New() any
+		// Add 将item累加到acc上，返回累加后的累加器
+		Add(acc, item any) any
+		// Result 返回累加器的聚合结果
+		Result(acc any) any
+	}
+
+	// KeyValue 是Aggregate输出的key及其聚合结果
+	KeyValue struct {
+		Key   any
+		Value any
+	}
+
+	// ValueFunc 用于从元素中取出参与计算的数值
+	ValueFunc func(item any) float64
+
+	averageAcc struct {
+		sum   float64
+		count int64
+	}
+
+	averaging struct {
+		fn ValueFunc
+	}
+
+	bestAcc struct {
+		item any
+		has  bool
+	}
+
+	// bestBy 保留less比较后最靠前的元素，用于实现MinBy和MaxBy
+	bestBy struct {
+		less LessFunc
+	}
+
+	counting struct{}
+
+	distinctCounting struct {
+		fn KeyFunc
+	}
+
+	summing struct {
+		fn ValueFunc
+	}
+)
+
+// Aggregate 按fn返回的key对流中元素进行聚合，流结束后按key首次出现的顺序输出每个key的KeyValue，
+// 与Group不同，每个key只保存aggregator的累加器，适合元素数量巨大的场景
+func (s Stream) Aggregate(fn KeyFunc, aggregator Aggregator) Stream {
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+		defer s.state.recover()
+
+		var keys []any
+		accs := make(map[any]any)
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+
+			key := fn(item)
+			acc, ok := accs[key]
+			if !ok {
+				acc = aggregator.New()
+				keys = append(keys, key)
+			}
+			accs[key] = aggregator.Add(acc, item)
+		}
+		if s.cancelled() {
+			return
+		}
+
+		for _, key := range keys {
+			if !s.send(source, KeyValue{
+				Key:   key,
+				Value: aggregator.Result(accs[key]),
+			}) {
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// Averaging 返回计算fn平均值的Aggregator，结果为float64
+func Averaging(fn ValueFunc) Aggregator {
+	return averaging{fn: fn}
+}
+
+// Counting 返回统计元素个数的Aggregator，结果为int64
+func Counting() Aggregator {
+	return counting{}
+}
+
+// DistinctCounting 返回统计fn返回的不同key个数的Aggregator，结果为int64
+func DistinctCounting(fn KeyFunc) Aggregator {
+	return distinctCounting{fn: fn}
+}
+
+// MaxBy 返回按less取最大元素的Aggregator，结果为最大的元素
+func MaxBy(less LessFunc) Aggregator {
+	return bestBy{less: reverseLess(less)}
+}
+
+// MinBy 返回按less取最小元素的Aggregator，结果为最小的元素
+func MinBy(less LessFunc) Aggregator {
+	return bestBy{less: less}
+}
+
+// Summing 返回计算fn之和的Aggregator，结果为float64
+func Summing(fn ValueFunc) Aggregator {
+	return summing{fn: fn}
+}
+
+func (a averaging) New() any {
+	return new(averageAcc)
+}
+
+func (a averaging) Add(acc, item any) any {
+	avg := acc.(*averageAcc)
+	avg.sum += a.fn(item)
+	avg.count++
+	return avg
+}
+
+func (a averaging) Result(acc any) any {
+	avg := acc.(*averageAcc)
+	if avg.count == 0 {
+		return float64(0)
+	}
+
+	return avg.sum / float64(avg.count)
+}
+
+func (b bestBy) New() any {
+	return new(bestAcc)
+}
+
+func (b bestBy) Add(acc, item any) any {
+	best := acc.(*bestAcc)
+	if !best.has || b.less(item, best.item) {
+		best.item = item
+		best.has = true
+	}
+	return best
+}
+
+func (b bestBy) Result(acc any) any {
+	return acc.(*bestAcc).item
+}
+
+func (c counting) New() any {
+	return new(int64)
+}
+
+func (c counting) Add(acc, item any) any {
+	count := acc.(*int64)
+	*count++
+	return count
+}
+
+func (c counting) Result(acc any) any {
+	return *acc.(*int64)
+}
+
+func (d distinctCounting) New() any {
+	return make(map[any]lang.PlaceholderType)
+}
+
+func (d distinctCounting) Add(acc, item any) any {
+	keys := acc.(map[any]lang.PlaceholderType)
+	keys[d.fn(item)] = lang.Placeholder
+	return keys
+}
+
+func (d distinctCounting) Result(acc any) any {
+	return int64(len(acc.(map[any]lang.PlaceholderType)))
+}
+
+func (sm summing) New() any {
+	return new(float64)
+}
+
+func (sm summing) Add(acc, item any) any {
+	sum := acc.(*float64)
+	*sum += sm.fn(item)
+	return sum
+}
+
+func (sm summing) Result(acc any) any {
+	return *acc.(*float64)
+}
@@ -0,0 +1,108 @@
+package fx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type aggEvent struct {
+	user    string
+	page    string
+	latency float64
+}
+
+// firstLetter 自定义Aggregator，拼接每个元素的首字母
+type firstLetter struct{}
+
+func (firstLetter) New() any {
+	return new(strings.Builder)
+}
+
+func (firstLetter) Add(acc, item any) any {
+	builder := acc.(*strings.Builder)
+	builder.WriteByte(item.(aggEvent).page[0])
+	return builder
+}
+
+func (firstLetter) Result(acc any) any {
+	return acc.(*strings.Builder).String()
+}
+
+func TestAggregate(t *testing.T) {
+	events := []any{
+		aggEvent{"u1", "home", 10},
+		aggEvent{"u2", "home", 30},
+		aggEvent{"u1", "cart", 20},
+		aggEvent{"u1", "home", 60},
+	}
+	byUser := func(item any) any {
+		return item.(aggEvent).user
+	}
+	latency := func(item any) float64 {
+		return item.(aggEvent).latency
+	}
+	less := func(a, b any) bool {
+		return a.(aggEvent).latency < b.(aggEvent).latency
+	}
+
+	tests := []struct {
+		name       string
+		aggregator Aggregator
+		expect     []KeyValue
+	}{
+		{
+			name:       "count",
+			aggregator: Counting(),
+			expect:     []KeyValue{{"u1", int64(3)}, {"u2", int64(1)}},
+		},
+		{
+			name:       "sum",
+			aggregator: Summing(latency),
+			expect:     []KeyValue{{"u1", float64(90)}, {"u2", float64(30)}},
+		},
+		{
+			name:       "avg",
+			aggregator: Averaging(latency),
+			expect:     []KeyValue{{"u1", float64(30)}, {"u2", float64(30)}},
+		},
+		{
+			name:       "min",
+			aggregator: MinBy(less),
+			expect:     []KeyValue{{"u1", events[0]}, {"u2", events[1]}},
+		},
+		{
+			name:       "max",
+			aggregator: MaxBy(less),
+			expect:     []KeyValue{{"u1", events[3]}, {"u2", events[1]}},
+		},
+		{
+			name: "distinct count",
+			aggregator: DistinctCounting(func(item any) any {
+				return item.(aggEvent).page
+			}),
+			expect: []KeyValue{{"u1", int64(2)}, {"u2", int64(1)}},
+		},
+		{
+			name:       "custom",
+			aggregator: firstLetter{},
+			expect:     []KeyValue{{"u1", "hch"}, {"u2", "h"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var result []KeyValue
+			err := Just(events...).Aggregate(byUser, test.aggregator).ForEach(func(item any) {
+				result = append(result, item.(KeyValue))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expect) {
+				t.Fatalf("expect %v, got %v", test.expect, result)
+			}
+		})
+	}
+}
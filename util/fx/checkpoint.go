@@ -0,0 +1,314 @@
+package fx
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const checkpointSuffix = ".checkpoint"
+
+type (
+	// CheckpointStore 保存流处理的进度，name用于区分不同的流
+	CheckpointStore interface {
+		// Load 返回name上次提交的偏移量，从未提交时返回0
+		Load(name string) (int64, error)
+		// Save 提交name的偏移量
+		Save(name string, offset int64) error
+	}
+
+	// CheckpointGenerateFunc 用于从offset处开始读取源，将每个元素及其之后的偏移量以Record写入source，
+	// ctx在流被取消或中止时取消，写入source时应同时监听ctx.Done()并及时返回
+	CheckpointGenerateFunc func(ctx context.Context, offset int64, source chan<- Record) error
+
+	// Checkpoint 记录流中已在sink处确认的元素，并将所有之前元素都已确认的最大偏移量提交到CheckpointStore，
+	// 恢复时从该偏移量开始读取，已处理但未提交的元素会被再次处理，即至少处理一次
+	Checkpoint struct {
+		store       CheckpointStore
+		name        string
+		commitEvery int
+		lock        sync.Mutex
+		offset      int64
+		committed   int64
+		acked       int
+		seq         uint64
+		queue       []*pendingRecord
+		pending     map[uint64]*pendingRecord
+	}
+
+	// FileCheckpointStore 将偏移量保存在dir目录下以name命名的文件中
+	FileCheckpointStore struct {
+		dir string
+	}
+
+	// Record 是FromCheckpoint产生的元素，Next为处理完该元素后恢复时应开始读取的偏移量，
+	// 经过Map等操作时应通过With保留Record，被过滤掉的Record需要调用Checkpoint.Ack，否则之后的偏移量不会被提交
+	Record struct {
+		Value any
+		Next  int64
+		seq   uint64
+	}
+
+	pendingRecord struct {
+		next  int64
+		acked bool
+	}
+)
+
+// FromCheckpoint 从cp上次提交的偏移量处开始调用generate构建流，流中的元素为Record，
+// generate返回的错误会中止整条流，流被取消后不会再清空source，未监听ctx的generate会一直阻塞在写入上
+func FromCheckpoint(ctx context.Context, cp *Checkpoint, generate CheckpointGenerateFunc) Stream {
+	source := make(chan any)
+	s := newStream(ctx, source)
+
+	go func() {
+		defer close(source)
+		defer s.state.recover()
+
+		genCtx, cancel := s.sourceContext()
+		defer cancel()
+		records := make(chan Record)
+		result := make(chan error, 1)
+		go func() {
+			defer close(records)
+			defer func() {
+				if r := recover(); r != nil {
+					result <- &PanicError{
+						Value: r,
+						Stack: debug.Stack(),
+					}
+				}
+			}()
+			result <- generate(genCtx, cp.Offset(), records)
+		}()
+
+		for record := range records {
+			if !s.send(source, cp.track(record)) {
+				return
+			}
+		}
+		if err := <-result; err != nil {
+			s.state.fail(err)
+		}
+	}()
+
+	return s
+}
+
+// LineRecords 返回按行读取r的CheckpointGenerateFunc，每行为一个不含换行符的string，
+// 偏移量为字节位置，恢复时通过Seek跳到上次提交的位置
+func LineRecords(r io.ReadSeeker) CheckpointGenerateFunc {
+	return func(ctx context.Context, offset int64, source chan<- Record) error {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if len(line) > 0 {
+				offset += int64(len(line))
+				record := Record{
+					Value: strings.TrimRight(line, "\r\n"),
+					Next:  offset,
+				}
+				select {
+				case source <- record:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// NewCheckpoint 从store中读取name上次提交的偏移量并返回Checkpoint，每确认commitEvery个元素提交一次
+func NewCheckpoint(store CheckpointStore, name string, commitEvery int) (*Checkpoint, error) {
+	offset, err := store.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	if commitEvery < 1 {
+		commitEvery = 1
+	}
+
+	return &Checkpoint{
+		store:       store,
+		name:        name,
+		commitEvery: commitEvery,
+		offset:      offset,
+		committed:   offset,
+		pending:     make(map[uint64]*pendingRecord),
+	}, nil
+}
+
+// NewFileCheckpointStore 返回将偏移量保存在dir目录下的FileCheckpointStore
+func NewFileCheckpointStore(dir string) *FileCheckpointStore {
+	return &FileCheckpointStore{
+		dir: dir,
+	}
+}
+
+// Ack 确认record已在sink处处理完成，重复确认会被忽略
+func (c *Checkpoint) Ack(record Record) error {
+	c.lock.Lock()
+	p, ok := c.pending[record.seq]
+	if !ok {
+		c.lock.Unlock()
+		return nil
+	}
+
+	delete(c.pending, record.seq)
+	p.acked = true
+	for len(c.queue) > 0 && c.queue[0].acked {
+		c.offset = c.queue[0].next
+		c.queue = c.queue[1:]
+	}
+	c.acked++
+	commit := c.acked >= c.commitEvery
+	c.lock.Unlock()
+
+	if commit {
+		return c.Commit()
+	}
+
+	return nil
+}
+
+// Commit 将所有之前元素都已确认的最大偏移量提交到store
+func (c *Checkpoint) Commit() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.acked = 0
+	if c.offset == c.committed {
+		return nil
+	}
+	if err := c.store.Save(c.name, c.offset); err != nil {
+		return err
+	}
+
+	c.committed = c.offset
+	return nil
+}
+
+// Offset 返回所有之前元素都已确认的最大偏移量
+func (c *Checkpoint) Offset() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.offset
+}
+
+// track 记录即将进入流的record，返回带有序号的record
+func (c *Checkpoint) track(record Record) Record {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.seq++
+	record.seq = c.seq
+	p := &pendingRecord{
+		next: record.Next,
+	}
+	c.pending[record.seq] = p
+	c.queue = append(c.queue, p)
+
+	return record
+}
+
+// Load 读取name的偏移量文件，文件不存在时返回0
+func (fs *FileCheckpointStore) Load(name string) (int64, error) {
+	content, err := os.ReadFile(fs.path(name))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("fx: invalid checkpoint %s: %w", fs.path(name), err)
+	}
+
+	return offset, nil
+}
+
+// Save 先写入临时文件再重命名，保证进程崩溃时偏移量文件不会损坏
+func (fs *FileCheckpointStore) Save(name string, offset int64) error {
+	if err := os.MkdirAll(fs.dir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(fs.dir, name+checkpointSuffix+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.WriteString(strconv.FormatInt(offset, 10)); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), fs.path(name))
+}
+
+func (fs *FileCheckpointStore) path(name string) string {
+	return filepath.Join(fs.dir, name+checkpointSuffix)
+}
+
+// With 返回保留偏移量但值为value的Record，用于在Map等操作中转换Record的值
+func (r Record) With(value any) Record {
+	r.Value = value
+	return r
+}
+
+// ForEachAck 对流中的每个Record调用fn处理其Value，处理完成后在cp中确认该Record，
+// 流结束后提交偏移量，流中出现非Record元素时中止整条流
+func (s Stream) ForEachAck(cp *Checkpoint, fn ForEachFunc) error {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+
+		record, ok := item.(Record)
+		if !ok {
+			s.state.fail(fmt.Errorf("fx: ForEachAck expects Record, got %T", item))
+			break
+		}
+
+		fn(record.Value)
+		if err := cp.Ack(record); err != nil {
+			s.state.fail(err)
+			break
+		}
+	}
+
+	if err := cp.Commit(); err != nil {
+		s.state.collect(err)
+	}
+
+	return s.complete()
+}
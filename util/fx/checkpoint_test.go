@@ -0,0 +1,212 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryCheckpointStore struct {
+	lock    sync.Mutex
+	offsets map[string]int64
+	saves   int
+}
+
+func (ms *memoryCheckpointStore) Load(name string) (int64, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	return ms.offsets[name], nil
+}
+
+func (ms *memoryCheckpointStore) Save(name string, offset int64) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.offsets[name] = offset
+	ms.saves++
+	return nil
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	store := NewFileCheckpointStore(dir)
+
+	offset, err := store.Load("job")
+	if err != nil || offset != 0 {
+		t.Fatalf("expect 0 for missing checkpoint, got %d, %v", offset, err)
+	}
+	if err = store.Save("job", 42); err != nil {
+		t.Fatal(err)
+	}
+	if offset, err = store.Load("job"); err != nil || offset != 42 {
+		t.Fatalf("expect 42, got %d, %v", offset, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "job.checkpoint" {
+		t.Fatalf("unexpected files %v", entries)
+	}
+}
+
+func TestCheckpointAck(t *testing.T) {
+	store := &memoryCheckpointStore{offsets: map[string]int64{"job": 5}}
+	cp, err := NewCheckpoint(store, "job", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Offset() != 5 {
+		t.Fatalf("expect resume from 5, got %d", cp.Offset())
+	}
+
+	a := cp.track(Record{Value: "a", Next: 10})
+	b := cp.track(Record{Value: "b", Next: 20})
+	c := cp.track(Record{Value: "c", Next: 30})
+
+	// 乱序确认时只提交所有之前元素都已确认的偏移量
+	if err = cp.Ack(b); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Offset() != 5 {
+		t.Fatalf("expect 5, got %d", cp.Offset())
+	}
+	if err = cp.Ack(a.With("A")); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Offset() != 20 || store.offsets["job"] != 20 {
+		t.Fatalf("expect 20 committed, got %d/%d", cp.Offset(), store.offsets["job"])
+	}
+
+	if err = cp.Ack(c); err != nil {
+		t.Fatal(err)
+	}
+	if err = cp.Ack(c); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Offset() != 30 || store.offsets["job"] != 20 {
+		t.Fatalf("expect 30 pending commit, got %d/%d", cp.Offset(), store.offsets["job"])
+	}
+	if err = cp.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if store.offsets["job"] != 30 || store.saves != 2 {
+		t.Fatalf("expect 30 committed in 2 saves, got %d in %d", store.offsets["job"], store.saves)
+	}
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(path, []byte("a\nb\nc\r\nd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewFileCheckpointStore(t.TempDir())
+
+	run := func(stopAfter int) ([]string, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		cp, err := NewCheckpoint(store, "lines", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var processed []string
+		err = FromCheckpoint(ctx, cp, LineRecords(file)).Map(func(item any) any {
+			record := item.(Record)
+			return record.With(strings.ToUpper(record.Value.(string)))
+		}, Ordered()).ForEachAck(cp, func(item any) {
+			processed = append(processed, item.(string))
+			if len(processed) == stopAfter {
+				// 模拟进程在处理完stopAfter个元素后崩溃
+				cancel()
+			}
+		})
+
+		return processed, err
+	}
+
+	all := []string{"A", "B", "C", "D"}
+	first, err := run(2)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+	// 取消时已取出的元素仍可能被处理
+	if len(first) < 2 || !reflect.DeepEqual(first, all[:len(first)]) {
+		t.Fatalf("unexpected first run %v", first)
+	}
+
+	second, err := run(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := all[len(first):]; len(second) != len(expect) || len(expect) > 0 && !reflect.DeepEqual(second, expect) {
+		t.Fatalf("expect resume with %v, got %v", expect, second)
+	}
+}
+
+func TestFromCheckpointError(t *testing.T) {
+	cp, err := NewCheckpoint(&memoryCheckpointStore{offsets: map[string]int64{}}, "job", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errRead := errors.New("read failed")
+	err = FromCheckpoint(context.Background(), cp, func(ctx context.Context, offset int64, source chan<- Record) error {
+		source <- Record{Value: 1, Next: 1}
+		return errRead
+	}).ForEachAck(cp, func(item any) {})
+	if !errors.Is(err, errRead) {
+		t.Fatalf("expect read error, got %v", err)
+	}
+	if cp.Offset() != 1 {
+		t.Fatalf("expect acknowledged offset 1, got %d", cp.Offset())
+	}
+}
+
+func TestFromCheckpointStopsGenerator(t *testing.T) {
+	cp, err := NewCheckpoint(&memoryCheckpointStore{offsets: map[string]int64{}}, "job", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = FromCheckpoint(ctx, cp, func(ctx context.Context, offset int64, source chan<- Record) error {
+		for i := offset; ; i++ {
+			select {
+			case source <- Record{Value: i, Next: i + 1}:
+			case <-ctx.Done():
+				stopped <- ctx.Err()
+				return ctx.Err()
+			}
+		}
+	}).ForEachAck(cp, func(item any) {
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+
+	select {
+	case err = <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expect context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("generate should stop after the stream is cancelled")
+	}
+}
@@ -0,0 +1,151 @@
+package fx
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Clock 为流中基于时间的操作提供当前时间和定时器，默认使用系统时钟，
+	// 可以通过WithClock替换为可控的实现，便于测试
+	Clock interface {
+		// Now 返回当前时间
+		Now() time.Time
+		// NewTimer 创建一个在d之后触发的定时器
+		NewTimer(d time.Duration) Timer
+	}
+
+	// Timer 是Clock创建的定时器
+	Timer interface {
+		// C 返回定时器触发时写入当时时间的channel
+		C() <-chan time.Time
+		// Stop 停止定时器，定时器已触发或已停止时返回false
+		Stop() bool
+	}
+
+	// ManualClock 是只有调用Advance时时间才会前进的Clock，用于测试基于时间的操作
+	ManualClock struct {
+		lock   sync.Mutex
+		cond   *sync.Cond
+		now    time.Time
+		timers []*manualTimer
+	}
+
+	manualTimer struct {
+		clock    *ManualClock
+		deadline time.Time
+		c        chan time.Time
+	}
+
+	realClock struct{}
+
+	realTimer struct {
+		timer *time.Timer
+	}
+)
+
+// NewManualClock 返回当前时间为now的ManualClock
+func NewManualClock(now time.Time) *ManualClock {
+	clock := &ManualClock{
+		now: now,
+	}
+	clock.cond = sync.NewCond(&clock.lock)
+
+	return clock
+}
+
+// Advance 将时间向前推进d，并触发所有到期的定时器
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+	c.timers = pending
+	c.cond.Broadcast()
+}
+
+// BlockUntil 阻塞直到有至少n个未触发的定时器，用于在Advance之前等待被测代码创建定时器
+func (c *ManualClock) BlockUntil(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// NewTimer 创建一个在时间推进d之后触发的定时器，d不大于0时立即触发
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	timer := &manualTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		timer.c <- c.now
+		return timer
+	}
+
+	c.timers = append(c.timers, timer)
+	c.cond.Broadcast()
+	return timer
+}
+
+// Now 返回当前时间
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Stop() bool {
+	c := t.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+
+	return false
+}
+
+// Now 返回系统当前时间
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer 基于time.Timer创建定时器
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{
+		timer: time.NewTimer(d),
+	}
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
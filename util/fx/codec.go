@@ -0,0 +1,87 @@
+package fx
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+type (
+	// Codec 定义了元素写入文件等外部存储时的编解码方式
+	Codec interface {
+		// NewEncoder 返回向w写入元素的Encoder
+		NewEncoder(w io.Writer) Encoder
+		// NewDecoder 返回从r读取元素的Decoder
+		NewDecoder(r io.Reader) Decoder
+	}
+
+	// Encoder 将元素编码后写入
+	Encoder interface {
+		Encode(item any) error
+	}
+
+	// Decoder 读取并解码下一个元素，读完时返回io.EOF
+	Decoder interface {
+		Decode() (any, error)
+	}
+
+	gobCodec[T any] struct{}
+
+	gobDecoder[T any] struct {
+		decoder *gob.Decoder
+	}
+
+	jsonLinesCodec[T any] struct{}
+
+	jsonLinesDecoder[T any] struct {
+		decoder *json.Decoder
+	}
+)
+
+// GobCodec 返回使用gob编码的Codec，元素解码为T
+func GobCodec[T any]() Codec {
+	return gobCodec[T]{}
+}
+
+// JSONLinesCodec 返回使用JSON Lines编码的Codec，每行一个元素，元素解码为T
+func JSONLinesCodec[T any]() Codec {
+	return jsonLinesCodec[T]{}
+}
+
+func (gobCodec[T]) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+func (gobCodec[T]) NewDecoder(r io.Reader) Decoder {
+	return gobDecoder[T]{
+		decoder: gob.NewDecoder(r),
+	}
+}
+
+func (d gobDecoder[T]) Decode() (any, error) {
+	var item T
+	if err := d.decoder.Decode(&item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (jsonLinesCodec[T]) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (jsonLinesCodec[T]) NewDecoder(r io.Reader) Decoder {
+	return jsonLinesDecoder[T]{
+		decoder: json.NewDecoder(r),
+	}
+}
+
+func (d jsonLinesDecoder[T]) Decode() (any, error) {
+	var item T
+	if err := d.decoder.Decode(&item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
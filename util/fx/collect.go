@@ -0,0 +1,103 @@
+package fx
+
+import (
+	"just4play/util/lang"
+	"strings"
+)
+
+// MergeFunc 用于合并ToMap中key相同的两个值。它接收已有的值和新的值作为参数，并返回合并后的值
+type MergeFunc func(old, new any) any
+
+// GroupingBy 按fn返回的key对流中元素分组，返回每个key对应的元素
+func (s Stream) GroupingBy(fn KeyFunc) (map[any][]any, error) {
+	groups := make(map[any][]any)
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+
+		key := fn(item)
+		groups[key] = append(groups[key], item)
+	}
+
+	return groups, s.complete()
+}
+
+// Joining 将流中元素的字符串表示以sep连接起来，非字符串元素通过lang.Repr转换
+func (s Stream) Joining(sep string) (string, error) {
+	var builder strings.Builder
+	var started bool
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+
+		if started {
+			builder.WriteString(sep)
+		}
+		builder.WriteString(lang.Repr(item))
+		started = true
+	}
+
+	return builder.String(), s.complete()
+}
+
+// Partitioned 按predicate将流中元素分为满足和不满足条件的两部分
+func (s Stream) Partitioned(predicate FilterFunc) (matched, unmatched []any, err error) {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+
+		if predicate(item) {
+			matched = append(matched, item)
+		} else {
+			unmatched = append(unmatched, item)
+		}
+	}
+
+	return matched, unmatched, s.complete()
+}
+
+// ToChannel 将流中元素依次写入pipe，直到流结束、被取消或出错，pipe由调用方负责关闭
+func (s Stream) ToChannel(pipe chan<- any) error {
+	s.pipeTo(pipe)
+	return s.complete()
+}
+
+// ToMap 将流中元素转换为map，key由keyFn生成，value由valueFn生成，
+// key相同时通过mergeFn合并，mergeFn为nil时后出现的值覆盖先出现的值
+func (s Stream) ToMap(keyFn KeyFunc, valueFn MapFunc, mergeFn MergeFunc) (map[any]any, error) {
+	result := make(map[any]any)
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+
+		key, val := keyFn(item), valueFn(item)
+		if old, ok := result[key]; ok && mergeFn != nil {
+			val = mergeFn(old, val)
+		}
+		result[key] = val
+	}
+
+	return result, s.complete()
+}
+
+// ToSlice 将流中所有元素按顺序放入slice
+func (s Stream) ToSlice() ([]any, error) {
+	var items []any
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+		items = append(items, item)
+	}
+
+	return items, s.complete()
+}
@@ -0,0 +1,114 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToSlice(t *testing.T) {
+	items, err := Just(1, 2, 3).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []any{1, 2, 3}) {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		select {
+		case source <- 1:
+		case <-ctx.Done():
+		}
+	}).ToSlice(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+}
+
+func TestToMap(t *testing.T) {
+	words := []any{"go", "gin", "php", "go"}
+	key := func(item any) any {
+		return item.(string)[0]
+	}
+	length := func(item any) any {
+		return len(item.(string))
+	}
+
+	replaced, err := Just(words...).ToMap(key, length, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replaced, map[any]any{byte('g'): 2, byte('p'): 3}) {
+		t.Fatalf("unexpected map %v", replaced)
+	}
+
+	merged, err := Just(words...).ToMap(key, length, func(old, new any) any {
+		return old.(int) + new.(int)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(merged, map[any]any{byte('g'): 7, byte('p'): 3}) {
+		t.Fatalf("unexpected map %v", merged)
+	}
+}
+
+func TestToChannel(t *testing.T) {
+	pipe := make(chan any, 3)
+	if err := Just(1, 2, 3).ToChannel(pipe); err != nil {
+		t.Fatal(err)
+	}
+	close(pipe)
+
+	var sum int
+	for item := range pipe {
+		sum += item.(int)
+	}
+	if sum != 6 {
+		t.Fatalf("expect 6, got %d", sum)
+	}
+}
+
+func TestGroupingBy(t *testing.T) {
+	groups, err := Just("golang", "google", "php", "python", "java").GroupingBy(func(item any) any {
+		return item.(string)[0]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[any][]any{
+		byte('g'): {"golang", "google"},
+		byte('p'): {"php", "python"},
+		byte('j'): {"java"},
+	}
+	if !reflect.DeepEqual(groups, expect) {
+		t.Fatalf("expect %v, got %v", expect, groups)
+	}
+}
+
+func TestJoining(t *testing.T) {
+	joined, err := Just("a", 1, 2.5, true).Joining(",")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined != "a,1,2.5,true" {
+		t.Fatalf("unexpected string %s", joined)
+	}
+}
+
+func TestPartitioned(t *testing.T) {
+	matched, unmatched, err := Just("go", "php", "gin").Partitioned(func(item any) bool {
+		return strings.HasPrefix(item.(string), "g")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matched, []any{"go", "gin"}) || !reflect.DeepEqual(unmatched, []any{"php"}) {
+		t.Fatalf("unexpected partitions %v %v", matched, unmatched)
+	}
+}
@@ -0,0 +1,186 @@
+package fx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchYears 查找下一次执行时间时最多向后查找的年数，超过时认为不会再执行，如2月30日
+const cronSearchYears = 5
+
+var (
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	weekdayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+type (
+	// cronSchedule 是解析后的cron表达式，每个字段以位图表示允许的取值
+	cronSchedule struct {
+		minute  uint64
+		hour    uint64
+		dom     uint64
+		month   uint64
+		dow     uint64
+		domStar bool
+		dowStar bool
+	}
+
+	cronField struct {
+		min   int
+		max   int
+		names map[string]int
+	}
+)
+
+// parseCron 解析标准的5字段cron表达式：分 时 日 月 周，支持*、列表、范围、步长、月份和星期的英文缩写，
+// 以及@hourly、@daily等描述符，日和周都不是*时满足任意一个即执行
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if descriptor, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("fx: invalid cron spec %q: expect 5 fields, got %d", spec, len(fields))
+	}
+
+	var sched cronSchedule
+	bits := []*uint64{&sched.minute, &sched.hour, &sched.dom, &sched.month, &sched.dow}
+	defs := []cronField{
+		{min: 0, max: 59},
+		{min: 0, max: 23},
+		{min: 1, max: 31},
+		{min: 1, max: 12, names: monthNames},
+		// 星期中的7与0都表示周日
+		{min: 0, max: 7, names: weekdayNames},
+	}
+	for i, field := range fields {
+		value, err := defs[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("fx: invalid cron spec %q: %w", spec, err)
+		}
+		*bits[i] = value
+	}
+
+	if sched.dow&(1<<7) != 0 {
+		sched.dow = sched.dow&^(1<<7) | 1
+	}
+	sched.domStar = strings.HasPrefix(fields[2], "*")
+	sched.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return &sched, nil
+}
+
+// next 返回t之后的下一次执行时间，按t的时区计算，找不到时返回零值
+func (cs *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.Year() + cronSearchYears
+
+	for t.Year() <= limit {
+		switch {
+		case cs.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !cs.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// dayMatches 返回t的日期是否满足日和周字段
+func (cs *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domStar || cs.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// parse 解析一个字段，返回允许取值的位图
+func (cf cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case expr == "*":
+			lo, hi = cf.min, cf.max
+		case strings.Contains(expr, "-"):
+			loExpr, hiExpr, _ := strings.Cut(expr, "-")
+			var err error
+			if lo, err = cf.value(loExpr); err != nil {
+				return 0, err
+			}
+			if hi, err = cf.value(hiExpr); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = cf.value(expr); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				hi = cf.max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q", part)
+		}
+
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+// value 解析字段中的单个值，可以是数字或英文缩写
+func (cf cronField) value(expr string) (int, error) {
+	if value, ok := cf.names[strings.ToLower(expr)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(expr)
+	if err != nil || value < cf.min || value > cf.max {
+		return 0, fmt.Errorf("value %q out of range [%d, %d]", expr, cf.min, cf.max)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,42 @@
+package fx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{"* * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Fatalf("expect error for %q", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, 1, 31, 23, 59, 30, 0, time.UTC)
+	tests := []struct {
+		spec   string
+		expect time.Time
+	}{
+		{"* * * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/15 9-17 * * mon-fri", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"30 12 29 feb *", time.Date(2024, 2, 29, 12, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		// 日和周都受限时满足任意一个即可，2024-02-04是周日
+		{"0 8 20 * 7", time.Date(2024, 2, 4, 8, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 2, 1, 0, 5, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		sched, err := parseCron(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := sched.next(base); !next.Equal(test.expect) {
+			t.Fatalf("%s: expect %v, got %v", test.spec, test.expect, next)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package fx
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"just4play/util/lang"
+	"math"
+	"time"
+)
+
+type (
+	// Deduper 记录Distinct中已经出现过的key
+	Deduper interface {
+		// Seen 返回key是否已经出现过，并将其记录为已出现
+		Seen(key any) bool
+	}
+
+	// bloomDeduper 基于布隆过滤器去重，内存固定，但有一定概率把未出现过的key误判为已出现
+	bloomDeduper struct {
+		bits   []uint64
+		size   uint64
+		hashes uint64
+	}
+
+	// lruDeduper 精确去重，但最多记录size个key，每个key在最后一次出现ttl后过期
+	lruDeduper struct {
+		size  int
+		ttl   time.Duration
+		clock Clock
+		order *list.List
+		keys  map[any]*list.Element
+	}
+
+	lruEntry struct {
+		key  any
+		seen time.Time
+	}
+
+	// mapDeduper 精确去重，记录所有出现过的key
+	mapDeduper struct {
+		keys map[any]lang.PlaceholderType
+	}
+)
+
+// WithBloomDedup 使Distinct使用布隆过滤器去重，expected为预计的不同key个数，fpRate为期望的误判率，
+// 内存只与expected和fpRate有关，被误判为重复的元素会被丢弃，不同key个数超过expected后误判率会上升
+func WithBloomDedup(expected int, fpRate float64) Option {
+	if expected < 1 {
+		panic("expected should be greater than 0")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		panic("fpRate should be in range (0, 1)")
+	}
+
+	return func(opts *rxOptions) {
+		opts.newDeduper = func(clock Clock) Deduper {
+			return newBloomDeduper(expected, fpRate)
+		}
+	}
+}
+
+// WithDeduper 使Distinct使用自定义的Deduper去重，deduper只能用于一个Distinct
+func WithDeduper(deduper Deduper) Option {
+	return func(opts *rxOptions) {
+		opts.newDeduper = func(clock Clock) Deduper {
+			return deduper
+		}
+	}
+}
+
+// WithLRUDedup 使Distinct最多记录size个最近出现的key，每个key在最后一次出现ttl后过期，
+// size或ttl为0时表示不限制，过期或被淘汰的key再次出现时会被当作新的元素
+func WithLRUDedup(size int, ttl time.Duration) Option {
+	if size < 0 || ttl < 0 {
+		panic("size and ttl should not be negative")
+	}
+
+	return func(opts *rxOptions) {
+		opts.newDeduper = func(clock Clock) Deduper {
+			return newLRUDeduper(size, ttl, clock)
+		}
+	}
+}
+
+// newBloomDeduper 按预计的key个数和误判率计算位数组大小和哈希函数个数
+func newBloomDeduper(expected int, fpRate float64) *bloomDeduper {
+	size := uint64(math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Round(float64(size) / float64(expected) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &bloomDeduper{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// Seen 使用双重哈希计算key对应的所有位，全部已置位时认为key已出现过
+func (bd *bloomDeduper) Seen(key any) bool {
+	h1, h2 := hashKey(key)
+	seen := true
+	for i := uint64(0); i < bd.hashes; i++ {
+		pos := (h1 + i*h2) % bd.size
+		mask := uint64(1) << (pos % 64)
+		if bd.bits[pos/64]&mask == 0 {
+			seen = false
+			bd.bits[pos/64] |= mask
+		}
+	}
+
+	return seen
+}
+
+// newLRUDeduper 返回一个lruDeduper
+func newLRUDeduper(size int, ttl time.Duration, clock Clock) *lruDeduper {
+	return &lruDeduper{
+		size:  size,
+		ttl:   ttl,
+		clock: clock,
+		order: list.New(),
+		keys:  make(map[any]*list.Element),
+	}
+}
+
+// Seen 返回key是否在未过期的记录中，并刷新key的出现时间
+func (ld *lruDeduper) Seen(key any) bool {
+	now := ld.clock.Now()
+	ld.expire(now)
+
+	if elem, ok := ld.keys[key]; ok {
+		elem.Value.(*lruEntry).seen = now
+		ld.order.MoveToFront(elem)
+		return true
+	}
+
+	ld.keys[key] = ld.order.PushFront(&lruEntry{
+		key:  key,
+		seen: now,
+	})
+	if ld.size > 0 && ld.order.Len() > ld.size {
+		ld.remove(ld.order.Back())
+	}
+
+	return false
+}
+
+// expire 淘汰过期的key，链表按出现时间排列，只需从尾部检查
+func (ld *lruDeduper) expire(now time.Time) {
+	if ld.ttl <= 0 {
+		return
+	}
+
+	for elem := ld.order.Back(); elem != nil; elem = ld.order.Back() {
+		if now.Sub(elem.Value.(*lruEntry).seen) < ld.ttl {
+			return
+		}
+		ld.remove(elem)
+	}
+}
+
+func (ld *lruDeduper) remove(elem *list.Element) {
+	ld.order.Remove(elem)
+	delete(ld.keys, elem.Value.(*lruEntry).key)
+}
+
+// newMapDeduper 返回一个mapDeduper
+func newMapDeduper() *mapDeduper {
+	return &mapDeduper{
+		keys: make(map[any]lang.PlaceholderType),
+	}
+}
+
+func (md *mapDeduper) Seen(key any) bool {
+	if _, ok := md.keys[key]; ok {
+		return true
+	}
+
+	md.keys[key] = lang.Placeholder
+	return false
+}
+
+// hashKey 计算key的两个哈希值，类型不同但字符串表示相同的key不会被认为相同
+func hashKey(key any) (uint64, uint64) {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%T:%s", key, lang.Repr(key))
+	h1 := hash.Sum64()
+	// 第二个哈希值需为奇数，保证双重哈希能覆盖所有位置
+	h2 := (h1>>32 | h1<<32) | 1
+
+	return h1, h2
+}
@@ -0,0 +1,104 @@
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRUDeduper(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	deduper := newLRUDeduper(2, time.Minute, clock)
+
+	var result []bool
+	for _, key := range []string{"a", "b", "a", "c", "b", "a"} {
+		result = append(result, deduper.Seen(key))
+	}
+	// c进入后淘汰了最久未出现的b
+	expect := []bool{false, false, true, false, false, false}
+	if !reflect.DeepEqual(result, expect) {
+		t.Fatalf("expect %v, got %v", expect, result)
+	}
+
+	clock.Advance(time.Second * 30)
+	if !deduper.Seen("a") {
+		t.Fatal("a should not expire")
+	}
+	clock.Advance(time.Second * 50)
+	if deduper.Seen("b") {
+		t.Fatal("b should expire")
+	}
+	if !deduper.Seen("a") {
+		t.Fatal("a refreshed 50s ago should not expire")
+	}
+}
+
+func TestBloomDeduper(t *testing.T) {
+	deduper := newBloomDeduper(10000, 0.01)
+	var falsePositives int
+	for i := 0; i < 10000; i++ {
+		if deduper.Seen(i) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Fatalf("too many false positives: %d", falsePositives)
+	}
+	for i := 0; i < 10000; i++ {
+		if !deduper.Seen(i) {
+			t.Fatalf("%d should be seen", i)
+		}
+	}
+	// 类型不同的key不会被当作相同
+	if deduper.Seen("1") || deduper.Seen(int64(2)) {
+		t.Fatal("keys with different types should not collide")
+	}
+}
+
+func TestDistinctOptions(t *testing.T) {
+	key := func(item any) any {
+		return item
+	}
+	tests := []struct {
+		name   string
+		opts   []Option
+		expect string
+	}{
+		{
+			name:   "map",
+			expect: "[1 2 3]",
+		},
+		{
+			name:   "lru",
+			opts:   []Option{WithLRUDedup(1, 0)},
+			expect: "[1 2 1 3 1 2]",
+		},
+		{
+			name:   "bloom",
+			opts:   []Option{WithBloomDedup(100, 0.001)},
+			expect: "[1 2 3]",
+		},
+		{
+			name:   "custom",
+			opts:   []Option{WithDeduper(newMapDeduper())},
+			expect: "[1 2 3]",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var items []any
+			err := Just(1, 2, 2, 1, 3, 1, 2).Distinct(key, test.opts...).ForEach(func(item any) {
+				items = append(items, item)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(items) != test.expect {
+				t.Fatalf("expect %s, got %v", test.expect, items)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package fx
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// BatchError 汇总了CollectErrors模式下流中产生的所有错误
+	BatchError struct {
+		errs []error
+	}
+
+	// PanicError 记录了流的某个阶段发生的panic及其调用栈
+	PanicError struct {
+		Value any
+		Stack []byte
+	}
+
+	// streamState 是同一条流的所有阶段共享的运行状态，记录各阶段产生的错误，
+	// 出现需要中止整条流的错误时关闭done通知所有阶段退出
+	streamState struct {
+		done          chan struct{}
+		doneOnce      sync.Once
+		lock          sync.Mutex
+		errs          []error
+		observerValue atomic.Value
+		stages        int32
+	}
+)
+
+// Error 返回所有错误信息，以分号分隔
+func (be *BatchError) Error() string {
+	msgs := make([]string, len(be.errs))
+	for i, err := range be.errs {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Errors 返回所有错误
+func (be *BatchError) Errors() []error {
+	return append([]error(nil), be.errs...)
+}
+
+// Unwrap 返回所有错误，使用Go 1.20及以上版本编译时errors.Is和errors.As能匹配到其中任意一个，
+// 更早的版本不识别Unwrap() []error，需要通过Errors逐个检查
+func (be *BatchError) Unwrap() []error {
+	return be.Errors()
+}
+
+// Error 返回panic的值和调用栈
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", pe.Value, pe.Stack)
+}
+
+// newStreamState 返回一个新的streamState
+func newStreamState() *streamState {
+	return &streamState{
+		done: make(chan struct{}),
+	}
+}
+
+// collect 记录err，但不中止流
+func (ss *streamState) collect(err error) {
+	ss.lock.Lock()
+	ss.errs = append(ss.errs, err)
+	ss.lock.Unlock()
+}
+
+// err 返回流中记录的错误，多个错误时返回BatchError
+func (ss *streamState) err() error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	switch len(ss.errs) {
+	case 0:
+		return nil
+	case 1:
+		return ss.errs[0]
+	default:
+		return &BatchError{
+			errs: append([]error(nil), ss.errs...),
+		}
+	}
+}
+
+// fail 记录err并中止整条流
+func (ss *streamState) fail(err error) {
+	ss.collect(err)
+	ss.doneOnce.Do(func() {
+		close(ss.done)
+	})
+}
+
+// recover 将当前goroutine中的panic转换为PanicError并中止整条流，需通过defer调用
+func (ss *streamState) recover() {
+	if r := recover(); r != nil {
+		ss.fail(&PanicError{
+			Value: r,
+			Stack: debug.Stack(),
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package fx
+
+const (
+	// BlockPolicy 某个下游的缓冲已满时阻塞，直到该下游消费，较慢的下游会拖慢所有下游
+	BlockPolicy BackpressurePolicy = iota
+	// DropPolicy 某个下游的缓冲已满时丢弃发给该下游的元素，不影响其他下游
+	DropPolicy
+)
+
+// BackpressurePolicy 指定Partition和Tee中下游消费较慢时的处理方式
+type BackpressurePolicy int
+
+// Partition 按fn返回的key将流拆分为n个流，key相同的元素总是写入同一个流，并保持它们的先后顺序，
+// 每个流都需要被消费，默认某个流不消费时会阻塞所有流，可以通过WithBackpressure指定缓冲大小和处理方式
+func (s Stream) Partition(n int, fn KeyFunc, opts ...Option) []Stream {
+	if n < 1 {
+		panic("n should be greater than 0")
+	}
+
+	return s.fanOut(n, opts, func(item any, emit func(i int, item any) bool) bool {
+		h, _ := hashKey(fn(item))
+		return emit(int(h%uint64(n)), item)
+	})
+}
+
+// Tee 将流复制为n个流，每个元素都会写入所有的流，元素本身不会被复制，
+// 每个流都需要被消费，默认某个流不消费时会阻塞所有流，可以通过WithBackpressure指定缓冲大小和处理方式
+func (s Stream) Tee(n int, opts ...Option) []Stream {
+	if n < 1 {
+		panic("n should be greater than 0")
+	}
+
+	return s.fanOut(n, opts, func(item any, emit func(i int, item any) bool) bool {
+		for i := 0; i < n; i++ {
+			if !emit(i, item) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WithBackpressure 指定Partition和Tee中每个下游的缓冲大小，以及缓冲已满时的处理方式
+func WithBackpressure(policy BackpressurePolicy, buffer int) Option {
+	if buffer < 0 {
+		panic("buffer should not be negative")
+	}
+
+	return func(opts *rxOptions) {
+		opts.backpressure = policy
+		opts.fanOutBuffer = buffer
+	}
+}
+
+// fanOut 创建n个下游流，由route决定每个元素写入哪些下游，route返回false时停止
+func (s Stream) fanOut(n int, opts []Option,
+	route func(item any, emit func(i int, item any) bool) bool) []Stream {
+	option := s.options(opts...)
+	pipes := make([]chan any, n)
+	streams := make([]Stream, n)
+	for i := range pipes {
+		pipes[i] = make(chan any, option.fanOutBuffer)
+		streams[i] = s.derive(pipes[i])
+	}
+
+	emit := func(i int, item any) bool {
+		if option.backpressure == DropPolicy {
+			select {
+			case pipes[i] <- item:
+			default:
+			}
+			return true
+		}
+
+		return s.send(pipes[i], item)
+	}
+
+	go func() {
+		defer func() {
+			for _, pipe := range pipes {
+				close(pipe)
+			}
+		}()
+		defer s.release()
+		defer s.state.recover()
+
+		for {
+			item, ok := s.recv()
+			if !ok || !route(item, emit) {
+				return
+			}
+		}
+	}()
+
+	return streams
+}
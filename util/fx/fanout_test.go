@@ -0,0 +1,108 @@
+package fx
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPartition(t *testing.T) {
+	streams := From(func(source chan<- any) {
+		for i := 0; i < 300; i++ {
+			source <- fmt.Sprintf("user%d:%d", i%10, i)
+		}
+	}).Partition(4, func(item any) any {
+		var user string
+		fmt.Sscanf(item.(string), "%5s", &user)
+		return user
+	})
+
+	var lock sync.Mutex
+	// 每个key所在的流及其最后一个序号
+	owners := make(map[string]int)
+	lasts := make(map[string]int)
+	total := 0
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		i, stream := i, stream
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream.ForEach(func(item any) {
+				var user string
+				var seq int
+				fmt.Sscanf(item.(string), "%5s:%d", &user, &seq)
+
+				lock.Lock()
+				defer lock.Unlock()
+				total++
+				if owner, ok := owners[user]; ok && owner != i {
+					t.Errorf("%s appears in stream %d and %d", user, owner, i)
+				}
+				owners[user] = i
+				if last, ok := lasts[user]; ok && last > seq {
+					t.Errorf("%s out of order: %d after %d", user, seq, last)
+				}
+				lasts[user] = seq
+			})
+		}()
+	}
+	wg.Wait()
+
+	if total != 300 {
+		t.Fatalf("expect 300 items, got %d", total)
+	}
+}
+
+func TestTee(t *testing.T) {
+	streams := Just(1, 2, 3, 4, 5).Tee(3, WithBackpressure(BlockPolicy, 2))
+
+	counts := make([]int, len(streams))
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		i, stream := i, stream
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts[i], _ = stream.Count()
+		}()
+	}
+	wg.Wait()
+
+	for i, count := range counts {
+		if count != 5 {
+			t.Fatalf("stream %d expect 5 items, got %d", i, count)
+		}
+	}
+}
+
+func TestTeeDropPolicy(t *testing.T) {
+	streams := From(func(source chan<- any) {
+		for i := 0; i < 100; i++ {
+			source <- i
+			time.Sleep(time.Millisecond)
+		}
+	}).Tee(2, WithBackpressure(DropPolicy, 1))
+
+	var fast, slow int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		fast, _ = streams[0].Count()
+	}()
+	go func() {
+		defer wg.Done()
+		streams[1].ForEach(func(item any) {
+			time.Sleep(time.Millisecond * 10)
+			slow++
+		})
+	}()
+	wg.Wait()
+
+	// 慢的下游丢弃元素，不会拖慢快的下游
+	if slow >= 100 || fast <= slow {
+		t.Fatalf("slow consumer should drop items, got fast %d, slow %d", fast, slow)
+	}
+}
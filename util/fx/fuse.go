@@ -0,0 +1,179 @@
+package fx
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// closureSuffix 匹配闭包函数名的后缀，如.func1
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+// fusedOp 是计划阶段中的一个无状态操作，apply返回false时丢弃该元素
+type fusedOp struct {
+	name  string
+	apply func(item any) (any, bool)
+}
+
+// plan 是尚未启动的无状态阶段，Filter、Map、MapErr构建时不启动goroutine，而是返回计划阶段，
+// 相邻且并发设置相同的计划阶段合并为一个，在第一次读取该流时才启动，
+// 由同一组worker对每个元素依次执行所有操作，从而省去中间的goroutine和channel
+type plan struct {
+	upstream Stream
+	ops      []fusedOp
+	option   *rxOptions
+	stage    *stage
+	once     sync.Once
+	source   <-chan any
+}
+
+// Explain 返回产生当前流的物理执行计划，每行为一个拥有独立goroutine的阶段，
+// fused列出合并到该阶段中依次执行的操作，尚未启动的计划阶段同样列出
+func (s Stream) Explain() string {
+	var stages []*stage
+	for st := s.stage; st != nil; st = st.parent {
+		stages = append(stages, st)
+	}
+
+	var builder strings.Builder
+	for i := len(stages) - 1; i >= 0; i-- {
+		st := stages[i]
+		fmt.Fprintf(&builder, "%s: %s", st, st.op)
+		if len(st.input) > 0 {
+			fmt.Fprintf(&builder, " fused: %s", strings.Join(st.input, " -> "))
+		}
+		builder.WriteByte('\n')
+	}
+
+	return builder.String()
+}
+
+// adopt 在当前流中新建读取other的阶段，other为计划阶段时将其启动
+func (s Stream) adopt(other Stream) Stream {
+	st := s.state.newStage()
+	st.op = callerOp(1)
+	adopted := s.deriveStage(other.input(), st)
+	adopted.drainSource = other.drainSource
+
+	return adopted
+}
+
+// fuse 将无状态操作追加到当前流的计划阶段中，当前流不是计划阶段、已被命名或并发设置不同时新建计划阶段，
+// 返回新的流，原有流不受影响
+func (s Stream) fuse(name string, option *rxOptions, apply func(item any) (any, bool)) Stream {
+	op := fusedOp{
+		name:  name,
+		apply: apply,
+	}
+	if p := s.plan; p != nil && p.stage.name.Load() == nil && p.option.sameWorkers(option) {
+		ops := make([]fusedOp, len(p.ops), len(p.ops)+1)
+		copy(ops, p.ops)
+		ops = append(ops, op)
+		p.stage.input = fusedNames(ops)
+
+		return Stream{
+			ctx:   s.ctx,
+			state: s.state,
+			stage: p.stage,
+			plan: &plan{
+				upstream: p.upstream,
+				ops:      ops,
+				option:   p.option,
+				stage:    p.stage,
+			},
+		}
+	}
+
+	st := s.state.newStage()
+	st.op = walkOp(option)
+	st.parent = s.stage
+	st.input = []string{name}
+	s.stage.consumer.CompareAndSwap(nil, st)
+
+	return Stream{
+		ctx:   s.ctx,
+		state: s.state,
+		stage: st,
+		plan: &plan{
+			upstream: s,
+			ops:      []fusedOp{op},
+			option:   option,
+			stage:    st,
+		},
+	}
+}
+
+// input 返回当前流的source，当前流为计划阶段时先将其启动
+func (s Stream) input() <-chan any {
+	if s.plan == nil {
+		return s.source
+	}
+
+	s.plan.once.Do(s.plan.start)
+	return s.plan.source
+}
+
+// start 启动计划阶段，worker数为1时在单个goroutine中依次处理元素
+func (p *plan) start() {
+	up := p.upstream
+	workers := func() int {
+		if p.option.unlimitedWorkers {
+			return 0
+		}
+		return p.option.workers
+	}
+	fn := up.observed(func(item any, pipe chan<- any) {
+		for _, op := range p.ops {
+			var ok bool
+			if item, ok = op.apply(item); !ok {
+				return
+			}
+		}
+		up.send(pipe, item)
+	}, p.stage, workers, p.option.clock)
+
+	switch {
+	case p.option.unlimitedWorkers:
+		p.source = up.walkUnlimited(fn, p.option, p.stage).source
+	case p.option.workers == minWorkers:
+		p.source = up.walkSerial(fn, p.stage).source
+	default:
+		p.source = up.walkLimited(fn, p.option, p.stage).source
+	}
+}
+
+// fusible 返回使用这些选项的无状态操作是否可以作为计划阶段合并执行
+func (ro *rxOptions) fusible() bool {
+	return !ro.ordered && !ro.guarded() && ro.adaptiveMax == 0
+}
+
+// sameWorkers 返回两组选项的并发设置是否相同，相同时对应的计划阶段可以合并
+func (ro *rxOptions) sameWorkers(other *rxOptions) bool {
+	return ro.workers == other.workers && ro.unlimitedWorkers == other.unlimitedWorkers
+}
+
+// callerOp 返回调用derive或newStream的操作名，用于Explain
+func callerOp(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+
+	name := runtime.FuncForPC(pc).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimPrefix(name, "fx.")
+	name = strings.TrimPrefix(name, "Stream.")
+
+	return closureSuffix.ReplaceAllString(name, "")
+}
+
+func fusedNames(fused []fusedOp) []string {
+	names := make([]string, len(fused))
+	for i, op := range fused {
+		names[i] = op.name
+	}
+
+	return names
+}
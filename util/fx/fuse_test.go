@@ -0,0 +1,207 @@
+package fx
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFusion(t *testing.T) {
+	s := Just(1, 2, 3, 4, 5, 6, 7, 8, 9, 10).Filter(func(item any) bool {
+		return item.(int)%2 == 0
+	}).Map(func(item any) any {
+		return item.(int) * 10
+	}).Filter(func(item any) bool {
+		return item.(int) > 20
+	})
+
+	// 相邻的计划阶段合并为一个阶段
+	if s.plan == nil || len(s.plan.ops) != 3 || s.stage.parent.parent != nil {
+		t.Fatal("expect 3 operations fused into one stage after the source")
+	}
+
+	items, err := s.ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].(int) < items[j].(int)
+	})
+	if !reflect.DeepEqual(items, []any{40, 60, 80, 100}) {
+		t.Fatalf("unexpected items %v", items)
+	}
+}
+
+func TestFusionLazy(t *testing.T) {
+	source := Just(1, 2, 3)
+	before := runtime.NumGoroutine()
+	s := source.Map(func(item any) any {
+		return item.(int) * 2
+	}).Filter(func(item any) bool {
+		return item.(int) > 2
+	}, WithWorkers(1))
+	// 计划阶段在读取前不启动goroutine
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expect no goroutines started before reading, before %d, after %d", before, after)
+	}
+
+	count, err := s.Count()
+	if err != nil || count != 2 {
+		t.Fatalf("expect 2 items, got %d, %v", count, err)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	s := Just(1, 2, 3).Filter(func(item any) bool {
+		return true
+	}).Map(func(item any) any {
+		return item
+	}).Map(func(item any) any {
+		return item
+	}, WithWorkers(1)).Named("identity").Filter(func(item any) bool {
+		return true
+	}, WithWorkers(1))
+	defer s.Done()
+
+	expect := "source: Range\n" +
+		"stage1: Walk(workers=16) fused: Filter -> Map\n" +
+		"identity: Walk(workers=1) fused: Map\n" +
+		"stage3: Walk(workers=1) fused: Filter\n"
+	if plan := s.Explain(); plan != expect {
+		t.Fatalf("expect plan:\n%s\ngot:\n%s", expect, plan)
+	}
+}
+
+func TestFusedErrors(t *testing.T) {
+	err := Just(1, 2, 3).Map(func(item any) any {
+		if item.(int) == 2 {
+			panic("boom")
+		}
+		return item
+	}, WithWorkers(1)).Done()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("expect PanicError, got %v", err)
+	}
+
+	errBad := errors.New("bad")
+	count, err := Just(1, 2, 3).MapErr(func(item any) (any, error) {
+		if item.(int) == 2 {
+			return nil, errBad
+		}
+		return item, nil
+	}, WithWorkers(1), CollectErrors()).Count()
+	if !errors.Is(err, errBad) || count != 2 {
+		t.Fatalf("expect 2 items and errBad, got %d, %v", count, err)
+	}
+}
+
+func TestFusedBeforeDirectReads(t *testing.T) {
+	odd := func(item any) bool {
+		return item.(int)%2 == 1
+	}
+
+	batches, err := Just(1, 2, 3, 4, 5, 6).Filter(odd, WithWorkers(1)).Batch(10, time.Second).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(batches, []any{[]any{1, 3, 5}}) {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+
+	joined, err := Just(1).Concat(Just(2, 3).Filter(odd, WithWorkers(1))).Sort(func(a, b any) bool {
+		return a.(int) < b.(int)
+	}).Joining(",")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined != "1,3" {
+		t.Fatalf("unexpected items %s", joined)
+	}
+
+	result, err := Just(1, 2, 3).Filter(odd, WithWorkers(1)).Reduce(func(pipe <-chan any) (any, error) {
+		var items []string
+		for item := range pipe {
+			items = append(items, strconv.Itoa(item.(int)))
+		}
+		return strings.Join(items, ","), nil
+	})
+	if err != nil || result != "1,3" {
+		t.Fatalf("unexpected result %v, %v", result, err)
+	}
+}
+
+func BenchmarkFusedPipeline(b *testing.B) {
+	benchmarkPipeline(b, func(s Stream, fn FilterFunc) Stream {
+		return s.Filter(fn)
+	}, func(s Stream, fn MapFunc) Stream {
+		return s.Map(fn)
+	})
+}
+
+func BenchmarkUnfusedPipeline(b *testing.B) {
+	// Walk不会被合并，每个操作各占一个阶段
+	benchmarkPipeline(b, func(s Stream, fn FilterFunc) Stream {
+		return s.Walk(func(item any, pipe chan<- any) {
+			if fn(item) {
+				pipe <- item
+			}
+		})
+	}, func(s Stream, fn MapFunc) Stream {
+		return s.Walk(func(item any, pipe chan<- any) {
+			pipe <- fn(item)
+		})
+	})
+}
+
+func BenchmarkFusedSerialPipeline(b *testing.B) {
+	benchmarkPipeline(b, func(s Stream, fn FilterFunc) Stream {
+		return s.Filter(fn, WithWorkers(1))
+	}, func(s Stream, fn MapFunc) Stream {
+		return s.Map(fn, WithWorkers(1))
+	})
+}
+
+func BenchmarkUnfusedSerialPipeline(b *testing.B) {
+	benchmarkPipeline(b, func(s Stream, fn FilterFunc) Stream {
+		return s.Walk(func(item any, pipe chan<- any) {
+			if fn(item) {
+				pipe <- item
+			}
+		}, WithWorkers(1))
+	}, func(s Stream, fn MapFunc) Stream {
+		return s.Walk(func(item any, pipe chan<- any) {
+			pipe <- fn(item)
+		}, WithWorkers(1))
+	})
+}
+
+func benchmarkPipeline(b *testing.B, filter func(s Stream, fn FilterFunc) Stream,
+	mapper func(s Stream, fn MapFunc) Stream) {
+	source := make([]any, 1000)
+	for i := range source {
+		source[i] = i
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := filter(Just(source...), func(item any) bool {
+			return item.(int)%2 == 0
+		})
+		s = mapper(s, func(item any) any {
+			return item.(int) * 3
+		})
+		count, err := filter(s, func(item any) bool {
+			return item.(int)%4 == 0
+		}).Count()
+		if err != nil || count != 250 {
+			b.Fatalf("unexpected result %d, %v", count, err)
+		}
+	}
+}
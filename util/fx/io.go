@@ -0,0 +1,297 @@
+package fx
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"just4play/util/lang"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FromCSV 读取r中的CSV记录构建流，hasHeader为true时第一行为表头，
+// 每条记录为以表头为key的map[string]string，否则为[]string，解析错误会中止整条流，
+// r实现了io.Closer时在流被取消后关闭以结束阻塞中的读取，正常读完或出错时由调用方负责关闭
+func FromCSV(r io.Reader, hasHeader bool) Stream {
+	return fromReader(context.Background(), r, readCSV(r, hasHeader))
+}
+
+// FromCSVContext 与FromCSV相同，但ctx取消时结束流并关闭r
+func FromCSVContext(ctx context.Context, r io.Reader, hasHeader bool) Stream {
+	return fromReader(ctx, r, readCSV(r, hasHeader))
+}
+
+// FromDir 按filepath.Glob的规则匹配pattern，以匹配到的普通文件的路径构建流，路径按字典序排列，
+// 只匹配pattern所在的一层目录，不会递归进入子目录，匹配后被删除的文件会被跳过
+func FromDir(pattern string) Stream {
+	return fromReader(context.Background(), nil, readDir(pattern))
+}
+
+// FromDirContext 与FromDir相同，但ctx取消时结束流
+func FromDirContext(ctx context.Context, pattern string) Stream {
+	return fromReader(ctx, nil, readDir(pattern))
+}
+
+// FromJSONLines 读取r中每行一个JSON值构建流，每个值解码为T，解码错误会中止整条流，
+// r实现了io.Closer时在流被取消后关闭以结束阻塞中的读取，正常读完或出错时由调用方负责关闭
+func FromJSONLines[T any](r io.Reader) Stream {
+	return fromReader(context.Background(), r, readJSONLines[T](r))
+}
+
+// FromJSONLinesContext 与FromJSONLines相同，但ctx取消时结束流并关闭r
+func FromJSONLinesContext[T any](ctx context.Context, r io.Reader) Stream {
+	return fromReader(ctx, r, readJSONLines[T](r))
+}
+
+// FromLines 读取r中的每一行构建流，每行为不含换行符的string，不限制行的长度，读取错误会中止整条流，
+// r实现了io.Closer时在流被取消后关闭以结束阻塞中的读取，正常读完或出错时由调用方负责关闭
+func FromLines(r io.Reader) Stream {
+	return fromReader(context.Background(), r, readLines(r))
+}
+
+// FromLinesContext 与FromLines相同，但ctx取消时结束流并关闭r
+func FromLinesContext(ctx context.Context, r io.Reader) Stream {
+	return fromReader(ctx, r, readLines(r))
+}
+
+// ToCSV 将流中的元素作为CSV记录写入w，header不为空时先写入表头，
+// 元素可以是[]string，或按header取值的map[string]string，w由调用方关闭
+func (s Stream) ToCSV(w io.Writer, header []string) error {
+	writer := csv.NewWriter(w)
+	if len(header) > 0 {
+		if err := writer.Write(header); err != nil {
+			s.state.fail(err)
+		}
+	}
+
+	return s.writeTo(func(item any) error {
+		switch record := item.(type) {
+		case []string:
+			return writer.Write(record)
+		case map[string]string:
+			if len(header) == 0 {
+				return fmt.Errorf("fx: ToCSV needs a header to write map records")
+			}
+
+			row := make([]string, len(header))
+			for i, name := range header {
+				row[i] = record[name]
+			}
+			return writer.Write(row)
+		default:
+			return fmt.Errorf("fx: ToCSV expects []string or map[string]string, got %T", item)
+		}
+	}, func() error {
+		writer.Flush()
+		return writer.Error()
+	})
+}
+
+// ToJSONLines 将流中的每个元素编码为一行JSON写入w，w由调用方关闭
+func (s Stream) ToJSONLines(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+
+	return s.writeTo(encoder.Encode, writer.Flush)
+}
+
+// ToWriter 将流中每个元素的字符串表示作为一行写入w，非字符串元素通过lang.Repr转换，w由调用方关闭
+func (s Stream) ToWriter(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+
+	return s.writeTo(func(item any) error {
+		if _, err := writer.WriteString(lang.Repr(item)); err != nil {
+			return err
+		}
+		return writer.WriteByte('\n')
+	}, writer.Flush)
+}
+
+// writeTo 依次对流中的元素调用write，出错时中止整条流，结束后调用flush
+func (s Stream) writeTo(write func(item any) error, flush func() error) error {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+
+		if err := write(item); err != nil {
+			s.state.fail(err)
+			break
+		}
+	}
+
+	if err := flush(); err != nil {
+		s.state.collect(err)
+	}
+
+	return s.complete()
+}
+
+// fromReader 在单独的goroutine中调用read构建流，read返回的错误会中止整条流，
+// r实现了io.Closer时在流被取消或ctx取消后关闭，以便结束阻塞中的读取
+func fromReader(ctx context.Context, r io.Reader, read func(emit func(item any) bool) error) Stream {
+	source := make(chan any)
+	s := newStream(ctx, source)
+	s.stage.op = callerOp(1)
+
+	go func() {
+		defer close(source)
+		defer s.state.recover()
+
+		err := s.closeOnCancel(r, func() error {
+			return read(func(item any) bool {
+				return s.send(source, item)
+			})
+		})
+		if err != nil && !s.cancelled() {
+			s.state.fail(err)
+		}
+	}()
+
+	return s
+}
+
+// closeOnCancel 执行run，r实现了io.Closer时在run执行期间流被取消或ctx取消后关闭r，run正常结束或出错时不关闭r
+func (s Stream) closeOnCancel(r io.Reader, run func() error) error {
+	closer, ok := r.(io.Closer)
+	if !ok {
+		return run()
+	}
+
+	var once sync.Once
+	closeReader := func() {
+		once.Do(func() {
+			closer.Close()
+		})
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-stop:
+		case <-s.ctx.Done():
+			closeReader()
+		case <-s.state.done:
+			closeReader()
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+		// read因流被取消而提前返回时，上面的goroutine可能先看到stop
+		if s.cancelled() {
+			closeReader()
+		}
+	}()
+
+	return run()
+}
+
+// readCSV 返回读取r中CSV记录的read函数，供fromReader使用
+func readCSV(r io.Reader, hasHeader bool) func(emit func(item any) bool) error {
+	return func(emit func(item any) bool) error {
+		reader := csv.NewReader(r)
+		var header []string
+		if hasHeader {
+			var err error
+			if header, err = reader.Read(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			var item any = record
+			if hasHeader {
+				// 字段数与表头不一致时reader.Read会返回csv.ErrFieldCount
+				row := make(map[string]string, len(header))
+				for i, name := range header {
+					row[name] = record[i]
+				}
+				item = row
+			}
+			if !emit(item) {
+				return nil
+			}
+		}
+	}
+}
+
+// readDir 返回按pattern匹配普通文件路径的read函数，供fromReader使用
+func readDir(pattern string) func(emit func(item any) bool) error {
+	return func(emit func(item any) bool) error {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				// 在Glob之后被删除
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() && !emit(path) {
+				return nil
+			}
+		}
+
+		return nil
+	}
+}
+
+// readJSONLines 返回将r中每行JSON解码为T的read函数，供fromReader使用
+func readJSONLines[T any](r io.Reader) func(emit func(item any) bool) error {
+	return func(emit func(item any) bool) error {
+		decoder := json.NewDecoder(r)
+		for {
+			var item T
+			if err := decoder.Decode(&item); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if !emit(item) {
+				return nil
+			}
+		}
+	}
+}
+
+// readLines 返回逐行读取r的read函数，供fromReader使用
+func readLines(r io.Reader) func(emit func(item any) bool) error {
+	return func(emit func(item any) bool) error {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if len(line) > 0 && !emit(strings.TrimRight(line, "\r\n")) {
+				return nil
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
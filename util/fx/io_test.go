@@ -0,0 +1,238 @@
+package fx
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = true
+	return nil
+}
+
+// blockingReader 的Read一直阻塞到被关闭
+type blockingReader struct {
+	closed chan struct{}
+}
+
+func (br *blockingReader) Read([]byte) (int, error) {
+	<-br.closed
+	return 0, io.ErrClosedPipe
+}
+
+func (br *blockingReader) Close() error {
+	close(br.closed)
+	return nil
+}
+
+func TestFromLines(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	reader := &closeRecorder{Reader: strings.NewReader("a\nb\r\n\n" + long)}
+	items, err := FromLines(reader).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []any{"a", "b", "", long}) {
+		t.Fatalf("unexpected lines %d", len(items))
+	}
+	// 正常读完时reader由调用方关闭
+	if reader.closed {
+		t.Fatal("expect reader not closed")
+	}
+
+	errRead := errors.New("read failed")
+	items, err = FromLines(io.MultiReader(strings.NewReader("a\n"), iotest.ErrReader(errRead))).ToSlice()
+	if !errors.Is(err, errRead) {
+		t.Fatalf("expect read error, got %v", err)
+	}
+	if !reflect.DeepEqual(items, []any{"a"}) {
+		t.Fatalf("unexpected lines %v", items)
+	}
+}
+
+func TestFromLinesCloseOnCancel(t *testing.T) {
+	reader, writer := io.Pipe()
+	go writer.Write([]byte("a\n"))
+
+	errBad := errors.New("bad")
+	err := FromLines(reader).MapErr(func(item any) (any, error) {
+		return nil, errBad
+	}).Done()
+	if !errors.Is(err, errBad) {
+		t.Fatalf("expect errBad, got %v", err)
+	}
+
+	// 流被中止后reader会被异步关闭，之后的写入失败
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err = writer.Write([]byte("b\n")); errors.Is(err, io.ErrClosedPipe) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect closed pipe, got %v", err)
+		}
+	}
+}
+
+func TestFromLinesContextCancel(t *testing.T) {
+	reader := &blockingReader{closed: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := FromLinesContext(ctx, reader).Count()
+		done <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expect context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream should stop after ctx cancelled")
+	}
+	select {
+	case <-reader.closed:
+	case <-time.After(time.Second):
+		t.Fatal("expect reader closed after ctx cancelled")
+	}
+}
+
+func TestFromCSV(t *testing.T) {
+	input := "name,lang\nayuan,go\nbob,php\n"
+	rows, err := FromCSV(strings.NewReader(input), true).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []any{
+		map[string]string{"name": "ayuan", "lang": "go"},
+		map[string]string{"name": "bob", "lang": "php"},
+	}
+	if !reflect.DeepEqual(rows, expect) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	records, err := FromCSV(strings.NewReader(input), false).Count()
+	if err != nil || records != 3 {
+		t.Fatalf("expect 3 records, got %d, %v", records, err)
+	}
+
+	_, err = FromCSV(strings.NewReader("a,b\n1\n"), true).ToSlice()
+	if !errors.Is(err, csv.ErrFieldCount) {
+		t.Fatalf("expect csv.ErrFieldCount, got %v", err)
+	}
+}
+
+func TestFromJSONLines(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	users, err := FromJSONLines[user](strings.NewReader(`{"name":"a","age":1}
+{"name":"b","age":2}
+`)).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(users, []any{user{"a", 1}, user{"b", 2}}) {
+		t.Fatalf("unexpected users %v", users)
+	}
+
+	if _, err = FromJSONLines[user](strings.NewReader("{\"name\":")).ToSlice(); err == nil {
+		t.Fatal("expect decode error")
+	}
+}
+
+func TestFromDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.txt", "a.txt", "c.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d.txt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// 指向不存在文件的链接与匹配后被删除的文件一样被跳过
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "e.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// 不递归进入子目录
+	if err := os.WriteFile(filepath.Join(dir, "d.txt", "f.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := FromDir(filepath.Join(dir, "*.txt")).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []any{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	if !reflect.DeepEqual(paths, expect) {
+		t.Fatalf("expect %v, got %v", expect, paths)
+	}
+
+	if _, err = FromDir("[").ToSlice(); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Fatalf("expect ErrBadPattern, got %v", err)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Just("a", 1, true).ToWriter(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "a\n1\ntrue\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	buf.Reset()
+	header := []string{"name", "lang"}
+	if err := Just(map[string]string{"name": "ayuan", "lang": "go"}, []string{"bob", "php"}).
+		ToCSV(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "name,lang\nayuan,go\nbob,php\n" {
+		t.Fatalf("unexpected csv %q", buf.String())
+	}
+	if err := Just(1).ToCSV(&buf, nil); err == nil {
+		t.Fatal("expect error for unsupported record")
+	}
+
+	buf.Reset()
+	if err := Just(map[string]int{"a": 1}, []int{2}).ToJSONLines(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "{\"a\":1}\n[2]\n" {
+		t.Fatalf("unexpected json lines %q", buf.String())
+	}
+
+	errWrite := errors.New("write failed")
+	if err := Just("a").ToWriter(failingWriter{errWrite}); !errors.Is(err, errWrite) {
+		t.Fatalf("expect write error, got %v", err)
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (fw failingWriter) Write([]byte) (int, error) {
+	return 0, fw.err
+}
@@ -0,0 +1,292 @@
+package fx
+
+const (
+	// InnerJoin 只输出两侧key都匹配的元素
+	InnerJoin JoinKind = iota
+	// LeftJoin 输出左侧的所有元素，没有匹配的右侧为nil
+	LeftJoin
+	// FullJoin 输出两侧的所有元素，没有匹配的一侧为nil
+	FullJoin
+)
+
+type (
+	// JoinKind 指定JoinBy的连接方式
+	JoinKind int
+
+	// JoinPair 是JoinBy输出的一对匹配的元素，外连接中没有匹配的一侧为nil
+	JoinPair struct {
+		Key   any
+		Left  any
+		Right any
+	}
+
+	// joinEmitFunc 输出一对匹配的元素，流被取消时返回false
+	joinEmitFunc func(key, left, right any) bool
+
+	// keyGroups 从按key排序的流中依次读取key相同的一组元素
+	keyGroups struct {
+		stream  Stream
+		keyFn   KeyFunc
+		equal   func(a, b any) bool
+		pending any
+		key     any
+		has     bool
+	}
+)
+
+// JoinBy 按leftKey和rightKey连接当前流和other，匹配的元素以JoinPair写入新流，kind指定连接方式
+// 默认使用哈希连接，同时读取两侧，以先读完的较小一侧建立哈希表，再流式的读取另一侧进行匹配；
+// 两侧已按key排序时，可以通过MergeJoin使用归并连接，只需缓存key相同的一组元素
+func (s Stream) JoinBy(other Stream, leftKey, rightKey KeyFunc, kind JoinKind, opts ...Option) Stream {
+	option := s.options(opts...)
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		// 连接可能提前结束，清空两侧以免上游阻塞
+		defer func() {
+			go drain(s.input())
+			go drain(other.input())
+		}()
+		defer s.state.recover()
+
+		emit := func(key, left, right any) bool {
+			return s.send(source, JoinPair{
+				Key:   key,
+				Left:  left,
+				Right: right,
+			})
+		}
+
+		right := s.adopt(other)
+		if option.keyLess != nil {
+			mergeJoin(s, right, leftKey, rightKey, option.keyLess, kind, emit)
+		} else {
+			hashJoin(s, right, leftKey, rightKey, kind, emit)
+		}
+
+		// 合并进来的流出错时同样中止连接后的流
+		if err := other.err(); err != nil {
+			s.state.fail(err)
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// MergeJoin 使JoinBy使用归并连接，要求两侧都已按less对key升序排列
+func MergeJoin(less LessFunc) Option {
+	return func(opts *rxOptions) {
+		opts.keyLess = less
+	}
+}
+
+// hashJoin 交替读取两侧，以先读完的一侧建立哈希表，再用另一侧进行匹配
+func hashJoin(left, right Stream, leftKey, rightKey KeyFunc, kind JoinKind, emit joinEmitFunc) {
+	var lefts, rights []any
+	for {
+		item, ok := left.recv()
+		if !ok {
+			if !left.cancelled() {
+				joinBuildLeft(lefts, right, rights, leftKey, rightKey, kind, emit)
+			}
+			return
+		}
+		lefts = append(lefts, item)
+
+		if item, ok = right.recv(); !ok {
+			if !right.cancelled() {
+				joinBuildRight(left, lefts, rights, leftKey, rightKey, kind, emit)
+			}
+			return
+		}
+		rights = append(rights, item)
+	}
+}
+
+// joinBuildLeft 以左侧建立哈希表，用右侧已读取的rights和剩余元素进行匹配
+func joinBuildLeft(lefts []any, right Stream, rights []any, leftKey, rightKey KeyFunc,
+	kind JoinKind, emit joinEmitFunc) {
+	keys := make([]any, len(lefts))
+	table := make(map[any][]any)
+	for i, item := range lefts {
+		keys[i] = leftKey(item)
+		table[keys[i]] = append(table[keys[i]], item)
+	}
+
+	matched := make(map[any]bool)
+	probe := func(item any) bool {
+		key := rightKey(item)
+		if matches, ok := table[key]; ok {
+			matched[key] = true
+			for _, each := range matches {
+				if !emit(key, each, item) {
+					return false
+				}
+			}
+		} else if kind == FullJoin {
+			return emit(key, nil, item)
+		}
+		return true
+	}
+
+	for _, item := range rights {
+		if !probe(item) {
+			return
+		}
+	}
+	for {
+		item, ok := right.recv()
+		if !ok {
+			break
+		}
+		if !probe(item) {
+			return
+		}
+	}
+	if right.cancelled() || kind == InnerJoin {
+		return
+	}
+
+	for i, item := range lefts {
+		if !matched[keys[i]] && !emit(keys[i], item, nil) {
+			return
+		}
+	}
+}
+
+// joinBuildRight 以右侧建立哈希表，用左侧已读取的lefts和剩余元素进行匹配
+func joinBuildRight(left Stream, lefts, rights []any, leftKey, rightKey KeyFunc,
+	kind JoinKind, emit joinEmitFunc) {
+	keys := make([]any, len(rights))
+	table := make(map[any][]any)
+	for i, item := range rights {
+		keys[i] = rightKey(item)
+		table[keys[i]] = append(table[keys[i]], item)
+	}
+
+	matched := make(map[any]bool)
+	probe := func(item any) bool {
+		key := leftKey(item)
+		if matches, ok := table[key]; ok {
+			matched[key] = true
+			for _, each := range matches {
+				if !emit(key, item, each) {
+					return false
+				}
+			}
+		} else if kind != InnerJoin {
+			return emit(key, item, nil)
+		}
+		return true
+	}
+
+	for _, item := range lefts {
+		if !probe(item) {
+			return
+		}
+	}
+	for {
+		item, ok := left.recv()
+		if !ok {
+			break
+		}
+		if !probe(item) {
+			return
+		}
+	}
+	if left.cancelled() || kind != FullJoin {
+		return
+	}
+
+	for i, item := range rights {
+		if !matched[keys[i]] && !emit(keys[i], nil, item) {
+			return
+		}
+	}
+}
+
+// mergeJoin 对按key排序的两侧进行归并连接
+func mergeJoin(left, right Stream, leftKey, rightKey KeyFunc, less LessFunc, kind JoinKind, emit joinEmitFunc) {
+	equal := func(a, b any) bool {
+		return !less(a, b) && !less(b, a)
+	}
+	lg := newKeyGroups(left, leftKey, equal)
+	rg := newKeyGroups(right, rightKey, equal)
+
+	lk, ls, lok := lg.next()
+	rk, rs, rok := rg.next()
+	for lok || rok {
+		switch {
+		case lok && (!rok || less(lk, rk)):
+			if kind == InnerJoin && !rok {
+				return
+			}
+			if kind != InnerJoin {
+				for _, item := range ls {
+					if !emit(lk, item, nil) {
+						return
+					}
+				}
+			}
+			lk, ls, lok = lg.next()
+		case rok && (!lok || less(rk, lk)):
+			if kind != FullJoin && !lok {
+				return
+			}
+			if kind == FullJoin {
+				for _, item := range rs {
+					if !emit(rk, nil, item) {
+						return
+					}
+				}
+			}
+			rk, rs, rok = rg.next()
+		default:
+			for _, l := range ls {
+				for _, r := range rs {
+					if !emit(lk, l, r) {
+						return
+					}
+				}
+			}
+			lk, ls, lok = lg.next()
+			rk, rs, rok = rg.next()
+		}
+	}
+}
+
+// newKeyGroups 返回一个keyGroups
+func newKeyGroups(stream Stream, keyFn KeyFunc, equal func(a, b any) bool) *keyGroups {
+	kg := &keyGroups{
+		stream: stream,
+		keyFn:  keyFn,
+		equal:  equal,
+	}
+	kg.advance()
+
+	return kg
+}
+
+// next 返回下一组key相同的元素，流读完时返回false
+func (kg *keyGroups) next() (key any, items []any, ok bool) {
+	if !kg.has {
+		return nil, nil, false
+	}
+
+	key = kg.key
+	items = append(items, kg.pending)
+	for kg.advance(); kg.has && kg.equal(kg.key, key); kg.advance() {
+		items = append(items, kg.pending)
+	}
+
+	return key, items, true
+}
+
+// advance 预读下一个元素
+func (kg *keyGroups) advance() {
+	kg.pending, kg.has = kg.stream.recv()
+	if kg.has {
+		kg.key = kg.keyFn(kg.pending)
+	}
+}
@@ -0,0 +1,121 @@
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type joinUser struct {
+	id   int
+	name string
+}
+
+type joinOrder struct {
+	userId int
+	amount int
+}
+
+func TestJoinBy(t *testing.T) {
+	users := []any{
+		joinUser{1, "alice"},
+		joinUser{2, "bob"},
+		joinUser{3, "carol"},
+	}
+	orders := []any{
+		joinOrder{1, 10},
+		joinOrder{1, 20},
+		joinOrder{3, 30},
+		joinOrder{4, 40},
+	}
+	userKey := func(item any) any {
+		return item.(joinUser).id
+	}
+	orderKey := func(item any) any {
+		return item.(joinOrder).userId
+	}
+	mergeJoin := MergeJoin(func(a, b any) bool {
+		return a.(int) < b.(int)
+	})
+
+	tests := []struct {
+		name   string
+		kind   JoinKind
+		orders []any
+		expect []string
+	}{
+		{
+			name:   "inner",
+			kind:   InnerJoin,
+			orders: orders,
+			expect: []string{"1:alice:10", "1:alice:20", "3:carol:30"},
+		},
+		{
+			name:   "left",
+			kind:   LeftJoin,
+			orders: orders,
+			expect: []string{"1:alice:10", "1:alice:20", "2:bob:-", "3:carol:30"},
+		},
+		{
+			name:   "full",
+			kind:   FullJoin,
+			orders: orders,
+			expect: []string{"1:alice:10", "1:alice:20", "2:bob:-", "3:carol:30", "4:-:40"},
+		},
+		{
+			name:   "inner with smaller right",
+			kind:   InnerJoin,
+			orders: orders[:2],
+			expect: []string{"1:alice:10", "1:alice:20"},
+		},
+		{
+			name:   "left with smaller right",
+			kind:   LeftJoin,
+			orders: orders[:2],
+			expect: []string{"1:alice:10", "1:alice:20", "2:bob:-", "3:carol:-"},
+		},
+		{
+			name:   "full with smaller right",
+			kind:   FullJoin,
+			orders: orders[2:],
+			expect: []string{"1:alice:-", "2:bob:-", "3:carol:30", "4:-:40"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			hashed := joinResult(t, Just(users...).JoinBy(Just(test.orders...), userKey, orderKey, test.kind))
+			if !reflect.DeepEqual(hashed, test.expect) {
+				t.Fatalf("hash join expect %v, got %v", test.expect, hashed)
+			}
+
+			merged := joinResult(t, Just(users...).JoinBy(Just(test.orders...), userKey, orderKey, test.kind, mergeJoin))
+			if !reflect.DeepEqual(merged, test.expect) {
+				t.Fatalf("merge join expect %v, got %v", test.expect, merged)
+			}
+		})
+	}
+}
+
+func joinResult(t *testing.T, stream Stream) []string {
+	var result []string
+	err := stream.ForEach(func(item any) {
+		pair := item.(JoinPair)
+		name, amount := "-", "-"
+		if pair.Left != nil {
+			name = pair.Left.(joinUser).name
+		}
+		if pair.Right != nil {
+			amount = fmt.Sprint(pair.Right.(joinOrder).amount)
+		}
+		result = append(result, fmt.Sprintf("%v:%s:%s", pair.Key, name, amount))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(result)
+	return result
+}
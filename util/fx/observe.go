@@ -0,0 +1,279 @@
+package fx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+type (
+	// Observer 观察流中各个阶段的运行情况，流的每个阶段在处理元素时都会调用它，实现需要并发安全
+	Observer interface {
+		// ItemIn 在名为stage的阶段从上游取得一个元素时调用
+		ItemIn(stage string)
+		// ItemOut 在名为stage的阶段输出的元素被下游取走时调用，queued为此时该阶段输出channel中仍在排队的元素个数
+		ItemOut(stage string, queued int)
+		// ItemDone 在Walk、Map等并发阶段的worker处理完一个元素时调用，latency为处理耗时，
+		// busy为包括该worker在内正在处理元素的worker数，workers为worker总数，不限数量时为0
+		ItemDone(stage string, latency time.Duration, busy, workers int)
+	}
+
+	// Metrics 是内置的Observer，汇总每个阶段的运行情况，可以输出为表格或Prometheus文本格式
+	Metrics struct {
+		lock   sync.Mutex
+		names  []string
+		stages map[string]*stageMetrics
+	}
+
+	stageMetrics struct {
+		in         uint64
+		out        uint64
+		done       uint64
+		latency    time.Duration
+		maxLatency time.Duration
+		busy       uint64
+		workers    int
+		queued     uint64
+		maxQueued  int
+	}
+
+	// stage 代表流中的一个阶段，consumer为从该阶段的输出中取元素的下游阶段，
+	// parent为该阶段读取的上游阶段，op为产生该阶段的操作，input为在该阶段中执行的上游融合操作
+	stage struct {
+		id       int32
+		name     atomic.Value
+		consumer atomic.Pointer[stage]
+		busy     int32
+		parent   *stage
+		op       string
+		input    []string
+	}
+
+	observerHolder struct {
+		observer Observer
+	}
+)
+
+// NewMetrics 返回一个新的Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		stages: make(map[string]*stageMetrics),
+	}
+}
+
+// WithObserver 设置流的观察者，传给任意接收Option的操作后作用于整条流的所有阶段
+func WithObserver(observer Observer) Option {
+	return func(opts *rxOptions) {
+		opts.observer = observer
+	}
+}
+
+// ItemDone 记录阶段的处理耗时和worker使用情况
+func (m *Metrics) ItemDone(stage string, latency time.Duration, busy, workers int) {
+	m.update(stage, func(sm *stageMetrics) {
+		sm.done++
+		sm.latency += latency
+		if latency > sm.maxLatency {
+			sm.maxLatency = latency
+		}
+		sm.busy += uint64(busy)
+		sm.workers = workers
+	})
+}
+
+// ItemIn 记录阶段取得的元素个数
+func (m *Metrics) ItemIn(stage string) {
+	m.update(stage, func(sm *stageMetrics) {
+		sm.in++
+	})
+}
+
+// ItemOut 记录阶段输出的元素个数和排队情况
+func (m *Metrics) ItemOut(stage string, queued int) {
+	m.update(stage, func(sm *stageMetrics) {
+		sm.out++
+		sm.queued += uint64(queued)
+		if queued > sm.maxQueued {
+			sm.maxQueued = queued
+		}
+	})
+}
+
+// Summary 以表格形式返回每个阶段的元素个数、平均和最大处理耗时、worker利用率和排队情况
+func (m *Metrics) Summary() string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "STAGE\tIN\tOUT\tAVG LATENCY\tMAX LATENCY\tUTILIZATION\tAVG QUEUE\tMAX QUEUE")
+	for _, name := range m.names {
+		sm := m.stages[name]
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%v\t%v\t%.1f%%\t%.1f\t%d\n", name, sm.in, sm.out,
+			sm.avgLatency(), sm.maxLatency, sm.utilization()*100, sm.avgQueued(), sm.maxQueued)
+	}
+	writer.Flush()
+
+	return builder.String()
+}
+
+// WritePrometheus 将每个阶段的运行情况以Prometheus文本格式写入w
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	metrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(sm *stageMetrics) any
+	}{
+		{"fx_stage_items_in_total", "counter", "Items received by the stage.",
+			func(sm *stageMetrics) any { return sm.in }},
+		{"fx_stage_items_out_total", "counter", "Items emitted by the stage.",
+			func(sm *stageMetrics) any { return sm.out }},
+		{"fx_stage_latency_seconds_sum", "counter", "Total time spent processing items.",
+			func(sm *stageMetrics) any { return sm.latency.Seconds() }},
+		{"fx_stage_latency_seconds_count", "counter", "Items processed by the stage workers.",
+			func(sm *stageMetrics) any { return sm.done }},
+		{"fx_stage_latency_seconds_max", "gauge", "Maximum time spent processing an item.",
+			func(sm *stageMetrics) any { return sm.maxLatency.Seconds() }},
+		{"fx_stage_worker_utilization", "gauge", "Average fraction of busy workers.",
+			func(sm *stageMetrics) any { return sm.utilization() }},
+		{"fx_stage_queue_depth_max", "gauge", "Maximum number of items queued in the stage output.",
+			func(sm *stageMetrics) any { return sm.maxQueued }},
+	}
+
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n",
+			metric.name, metric.help, metric.name, metric.kind); err != nil {
+			return err
+		}
+		for _, name := range m.names {
+			if _, err := fmt.Fprintf(w, "%s{stage=%q} %v\n",
+				metric.name, name, metric.value(m.stages[name])); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// update 在锁内更新阶段的统计数据，阶段按第一次出现的顺序排列
+func (m *Metrics) update(stage string, fn func(sm *stageMetrics)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	sm, ok := m.stages[stage]
+	if !ok {
+		sm = new(stageMetrics)
+		m.stages[stage] = sm
+		m.names = append(m.names, stage)
+	}
+	fn(sm)
+}
+
+// Named 为产生当前流的阶段命名，名称用于Observer的统计，默认为source、stage1、stage2等，
+// 命名后的计划阶段不再与之后的Filter、Map、MapErr合并
+func (s Stream) Named(name string) Stream {
+	s.stage.name.Store(name)
+	return s
+}
+
+// observed 为Walk中的fn记录处理耗时和worker使用情况
+func (s Stream) observed(fn WalkFunc, st *stage, workers func() int, clock Clock) WalkFunc {
+	return func(item any, pipe chan<- any) {
+		busy := atomic.AddInt32(&st.busy, 1)
+		defer atomic.AddInt32(&st.busy, -1)
+
+		observer := s.state.observer()
+		if observer == nil {
+			fn(item, pipe)
+			return
+		}
+
+		start := clock.Now()
+		fn(item, pipe)
+		observer.ItemDone(st.String(), clock.Now().Sub(start), int(busy), workers())
+	}
+}
+
+// observeRecv 在从当前流取得一个元素后调用，通知观察者该元素离开了产生它的阶段并进入了下游阶段
+func (s Stream) observeRecv() {
+	observer := s.state.observer()
+	if observer == nil {
+		return
+	}
+
+	observer.ItemOut(s.stage.String(), len(s.input()))
+	if consumer := s.stage.consumer.Load(); consumer != nil {
+		observer.ItemIn(consumer.String())
+	}
+}
+
+// options 构建操作的选项，设置了观察者时将其关联到整条流
+func (s Stream) options(opts ...Option) *rxOptions {
+	option := buildOptions(opts...)
+	if option.observer != nil {
+		s.state.observerValue.Store(observerHolder{observer: option.observer})
+	}
+
+	return option
+}
+
+// String 返回阶段的名称
+func (st *stage) String() string {
+	if name, ok := st.name.Load().(string); ok {
+		return name
+	}
+	if st.id == 0 {
+		return "source"
+	}
+
+	return fmt.Sprintf("stage%d", st.id)
+}
+
+func (sm *stageMetrics) avgLatency() time.Duration {
+	if sm.done == 0 {
+		return 0
+	}
+
+	return sm.latency / time.Duration(sm.done)
+}
+
+func (sm *stageMetrics) avgQueued() float64 {
+	if sm.out == 0 {
+		return 0
+	}
+
+	return float64(sm.queued) / float64(sm.out)
+}
+
+func (sm *stageMetrics) utilization() float64 {
+	if sm.done == 0 || sm.workers == 0 {
+		return 0
+	}
+
+	return float64(sm.busy) / float64(sm.done) / float64(sm.workers)
+}
+
+// newStage 创建流中的下一个阶段
+func (ss *streamState) newStage() *stage {
+	return &stage{
+		id: atomic.AddInt32(&ss.stages, 1) - 1,
+	}
+}
+
+// observer 返回流的观察者，未设置时返回nil
+func (ss *streamState) observer() Observer {
+	if holder, ok := ss.observerValue.Load().(observerHolder); ok {
+		return holder.observer
+	}
+
+	return nil
+}
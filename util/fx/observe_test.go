@@ -0,0 +1,84 @@
+package fx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObserver(t *testing.T) {
+	metrics := NewMetrics()
+	count, err := Just(1, 2, 3, 4, 5, 6, 7, 8, 9, 10).Map(func(item any) any {
+		time.Sleep(time.Millisecond)
+		return item
+	}, WithWorkers(2), WithObserver(metrics)).Named("enrich").Filter(func(item any) bool {
+		return item.(int)%2 == 0
+	}).Named("even").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Fatalf("expect 5, got %d", count)
+	}
+
+	expect := map[string][2]uint64{
+		"source": {0, 10},
+		"enrich": {10, 10},
+		"even":   {10, 5},
+	}
+	for name, counts := range expect {
+		sm, ok := metrics.stages[name]
+		if !ok {
+			t.Fatalf("stage %s not observed", name)
+		}
+		if sm.in != counts[0] || sm.out != counts[1] {
+			t.Fatalf("stage %s: expect in/out %v, got %d/%d", name, counts, sm.in, sm.out)
+		}
+	}
+
+	enrich := metrics.stages["enrich"]
+	if enrich.done != 10 || enrich.workers != 2 || enrich.avgLatency() < time.Millisecond {
+		t.Fatalf("unexpected enrich metrics %+v", *enrich)
+	}
+	if utilization := enrich.utilization(); utilization <= 0 || utilization > 1 {
+		t.Fatalf("unexpected utilization %v", utilization)
+	}
+
+	summary := metrics.Summary()
+	if !strings.HasPrefix(summary, "STAGE") || !strings.Contains(summary, "enrich") {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+
+	var builder strings.Builder
+	if err = metrics.WritePrometheus(&builder); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE fx_stage_items_in_total counter",
+		`fx_stage_items_in_total{stage="enrich"} 10`,
+		`fx_stage_items_out_total{stage="even"} 5`,
+		`fx_stage_latency_seconds_count{stage="enrich"} 10`,
+	} {
+		if !strings.Contains(builder.String(), line+"\n") {
+			t.Fatalf("missing %q in:\n%s", line, builder.String())
+		}
+	}
+}
+
+func TestStageNames(t *testing.T) {
+	s := Just(1)
+	if name := s.stage.String(); name != "source" {
+		t.Fatalf("expect source, got %s", name)
+	}
+
+	mapped := s.Map(func(item any) any {
+		return item
+	})
+	if name := mapped.stage.String(); name != "stage1" {
+		t.Fatalf("expect stage1, got %s", name)
+	}
+	if name := mapped.Named("enrich").stage.String(); name != "enrich" {
+		t.Fatalf("expect enrich, got %s", name)
+	}
+	_ = mapped.Done()
+}
@@ -0,0 +1,211 @@
+package fx
+
+import "time"
+
+// tokenBucket 是令牌桶限流器，以rate个/秒的速度生成令牌，最多积攒burst个
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket 返回一个令牌已满的tokenBucket
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// reserve 取出一个令牌，返回需要等待多久令牌才可用
+func (tb *tokenBucket) reserve(now time.Time) time.Duration {
+	if elapsed := now.Sub(tb.last); elapsed > 0 {
+		tb.tokens += elapsed.Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+		tb.last = now
+	}
+
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+
+	// 令牌不足时预支，等待生成足够的令牌
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// Debounce 防抖，元素进入后d时间内没有新元素时才输出该元素，期间进入的新元素会替换它，
+// 流结束时输出最后一个未输出的元素，可以通过WithClock指定时钟
+func (s Stream) Debounce(d time.Duration, opts ...Option) Stream {
+	if d <= 0 {
+		panic("d should be greater than 0")
+	}
+
+	clock := s.options(opts...).clock
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+
+		var pending any
+		var timer Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			var expire <-chan time.Time
+			if timer != nil {
+				expire = timer.C()
+			}
+
+			select {
+			case item, ok := <-s.input():
+				if !ok {
+					if timer != nil {
+						s.send(source, pending)
+					}
+					return
+				}
+				s.observeRecv()
+
+				if timer != nil {
+					timer.Stop()
+				}
+				pending = item
+				timer = clock.NewTimer(d)
+			case <-expire:
+				timer = nil
+				if !s.send(source, pending) {
+					return
+				}
+				pending = nil
+			case <-s.ctx.Done():
+				return
+			case <-s.state.done:
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// Sample 采样，每隔d输出一次这段时间内进入的最后一个元素，这段时间内没有元素时不输出，
+// 流结束时输出最后一个未输出的元素，可以通过WithClock指定时钟
+func (s Stream) Sample(d time.Duration, opts ...Option) Stream {
+	if d <= 0 {
+		panic("d should be greater than 0")
+	}
+
+	clock := s.options(opts...).clock
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+
+		var latest any
+		var has bool
+		// 采样时间按计划推进，不受定时器触发延迟的影响
+		next := clock.Now().Add(d)
+		timer := clock.NewTimer(d)
+		defer func() {
+			// timer会被重新创建，需要在退出时取最新的值
+			timer.Stop()
+		}()
+
+		for {
+			select {
+			case item, ok := <-s.input():
+				if !ok {
+					if has {
+						s.send(source, latest)
+					}
+					return
+				}
+				s.observeRecv()
+
+				latest = item
+				has = true
+			case <-timer.C():
+				if has {
+					if !s.send(source, latest) {
+						return
+					}
+					latest = nil
+					has = false
+				}
+
+				next = next.Add(d)
+				timer = clock.NewTimer(next.Sub(clock.Now()))
+			case <-s.ctx.Done():
+				return
+			case <-s.state.done:
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// Throttle 基于令牌桶限速，每秒最多输出rate个元素，允许burst个元素的突发，
+// 超出速率的元素会等待而不会被丢弃，从而对上游形成反压，可以通过WithClock指定时钟
+// 放在Walk之前可以限制调用下游服务的频率
+func (s Stream) Throttle(rate float64, burst int, opts ...Option) Stream {
+	if rate <= 0 {
+		panic("rate should be greater than 0")
+	}
+	if burst < 1 {
+		panic("burst should be greater than 0")
+	}
+
+	clock := s.options(opts...).clock
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+
+		bucket := newTokenBucket(rate, burst, clock.Now())
+		for {
+			item, ok := s.recv()
+			if !ok {
+				return
+			}
+
+			if wait := bucket.reserve(clock.Now()); wait > 0 && !s.wait(clock, wait) {
+				return
+			}
+			if !s.send(source, item) {
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// wait 等待d时间，流被取消时立即返回false
+func (s Stream) wait(clock Clock, d time.Duration) bool {
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-s.ctx.Done():
+		return false
+	case <-s.state.done:
+		return false
+	}
+}
@@ -0,0 +1,94 @@
+package fx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(10, 2, now)
+
+	// 突发的两个令牌无需等待
+	if wait := bucket.reserve(now); wait != 0 {
+		t.Fatalf("expect no wait, got %v", wait)
+	}
+	if wait := bucket.reserve(now); wait != 0 {
+		t.Fatalf("expect no wait, got %v", wait)
+	}
+	if wait := bucket.reserve(now); wait != time.Millisecond*100 {
+		t.Fatalf("expect 100ms, got %v", wait)
+	}
+	// 预支的令牌在100ms后补齐
+	if wait := bucket.reserve(now.Add(time.Millisecond * 100)); wait != time.Millisecond*100 {
+		t.Fatalf("expect 100ms, got %v", wait)
+	}
+	if wait := bucket.reserve(now.Add(time.Second)); wait != 0 {
+		t.Fatalf("expect no wait, got %v", wait)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	start := time.Now()
+	count, err := Just(1, 2, 3, 4, 5, 6, 7, 8, 9, 10).Throttle(100, 2).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 {
+		t.Fatalf("expect 10, got %d", count)
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*80 {
+		t.Fatalf("expect at least 80ms, got %v", elapsed)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	clock := newSyncClock()
+	items := make(chan any)
+	out := consume(Range(items).Debounce(time.Millisecond*50, WithClock(clock)))
+
+	// 每个元素进入时都会重新创建定时器
+	items <- 1
+	items <- 2
+	items <- 3
+	clock.await(3)
+	clock.Advance(time.Millisecond * 50)
+	results := []any{<-out}
+
+	items <- 4
+	items <- 5
+	close(items)
+	for item := range out {
+		results = append(results, item)
+	}
+
+	expect := []any{3, 5}
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expect %v, got %v", expect, results)
+	}
+}
+
+func TestSample(t *testing.T) {
+	clock := newSyncClock()
+	items := make(chan any)
+	out := consume(Range(items).Sample(time.Millisecond*100, WithClock(clock)))
+
+	// 开始时读取时间并创建定时器
+	clock.await(2)
+	items <- 1
+	items <- 2
+	clock.Advance(time.Millisecond * 100)
+	results := []any{<-out}
+
+	items <- 3
+	close(items)
+	for item := range out {
+		results = append(results, item)
+	}
+
+	expect := []any{2, 3}
+	if !reflect.DeepEqual(results, expect) {
+		t.Fatalf("expect %v, got %v", expect, results)
+	}
+}
@@ -0,0 +1,273 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"just4play/util/lang"
+	"math/rand"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultBackoffFactor  = 2
+)
+
+// ErrItemTimeout 表示单个元素的处理时间超过了WithItemTimeout设置的时长
+var ErrItemTimeout = errors.New("fx: item processing timed out")
+
+type (
+	// DeadLetterFunc 用于接收重试耗尽后仍处理失败的元素，err为*ItemError
+	DeadLetterFunc func(item any, err error)
+
+	// ItemError 记录了单个元素在所有尝试后仍然失败的原因
+	ItemError struct {
+		Item     any
+		Attempts int
+		Err      error
+	}
+
+	// RetryPolicy 定义了元素处理失败时的重试方式，两次尝试之间按指数退避等待
+	RetryPolicy struct {
+		// MaxAttempts 最多尝试的次数，包括第一次，小于1时视为1
+		MaxAttempts int
+		// InitialBackoff 第一次重试前的等待时间，默认100ms
+		InitialBackoff time.Duration
+		// MaxBackoff 等待时间的上限，为0时不限制
+		MaxBackoff time.Duration
+		// Factor 每次重试后等待时间的增长倍数，默认2
+		Factor float64
+		// Jitter 等待时间随机浮动的比例，取值[0, 1]，用于避免大量元素同时重试
+		Jitter float64
+	}
+)
+
+// Error 返回元素失败的原因和尝试次数
+func (ie *ItemError) Error() string {
+	return fmt.Sprintf("fx: item %v failed after %d attempt(s): %v", ie.Item, ie.Attempts, ie.Err)
+}
+
+// Unwrap 返回最后一次尝试的错误
+func (ie *ItemError) Unwrap() error {
+	return ie.Err
+}
+
+// WithDeadLetter 设置Walk、Map等操作中重试耗尽后仍失败的元素的接收方，设置后这些元素不再中止流或计入终结操作返回的错误
+func WithDeadLetter(sink DeadLetterFunc) Option {
+	return func(opts *rxOptions) {
+		opts.deadLetter = sink
+	}
+}
+
+// WithItemTimeout 限制Walk、Map等操作处理单个元素的时长，超时的元素视为处理失败并释放其占用的worker，
+// 超时的fn在后台继续运行直到返回，其之后的输出会被丢弃，MapContext、WalkContext中的fn可以通过ctx感知超时并及时返回，
+// 每个阶段在后台运行的超时尝试最多与worker数相同，达到上限后新的超时会等待其中一个返回，以免对下游的并发调用无限增长
+func WithItemTimeout(d time.Duration) Option {
+	return func(opts *rxOptions) {
+		opts.itemTimeout = d
+	}
+}
+
+// WithRetry 设置Walk、Map等操作中元素处理失败时的重试方式，返回错误、panic和超时都会触发重试，
+// 失败的尝试的输出不会写入下游，因此每次尝试的输出在成功前会先缓存起来
+func WithRetry(policy RetryPolicy) Option {
+	return func(opts *rxOptions) {
+		opts.retry = &policy
+	}
+}
+
+// guarded 返回是否需要为Walk中的fn加上超时、重试或死信处理
+func (ro *rxOptions) guarded() bool {
+	return ro.itemTimeout > 0 || ro.retry != nil || ro.deadLetter != nil
+}
+
+// attempts 返回最多尝试的次数
+func (rp *RetryPolicy) attempts() int {
+	if rp == nil || rp.MaxAttempts < 1 {
+		return 1
+	}
+
+	return rp.MaxAttempts
+}
+
+// backoff 返回第attempt次尝试失败后的等待时间
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(rp.InitialBackoff)
+	if d <= 0 {
+		d = float64(defaultInitialBackoff)
+	}
+	factor := rp.Factor
+	if factor <= 0 {
+		factor = defaultBackoffFactor
+	}
+
+	for i := 1; i < attempt; i++ {
+		d *= factor
+		if rp.MaxBackoff > 0 && d >= float64(rp.MaxBackoff) {
+			break
+		}
+	}
+	if rp.MaxBackoff > 0 && d > float64(rp.MaxBackoff) {
+		d = float64(rp.MaxBackoff)
+	}
+	if rp.Jitter > 0 {
+		d += d * rp.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(d)
+}
+
+// attempt 执行一次fn，未设置超时时在当前goroutine中执行，只尝试一次时输出直接写入pipe，否则先缓存起来；
+// 设置了超时时在单独的goroutine中执行，超时、流被取消或中止后取消传给fn的ctx并立即返回，
+// 缓存的输出在成功后由调用方写入下游
+func (s Stream) attempt(fn WalkContextFunc, item any, pipe chan<- any, option *rxOptions,
+	abandoned chan lang.PlaceholderType) ([]any, error) {
+	if option.itemTimeout <= 0 {
+		if option.retry.attempts() == 1 {
+			return nil, call(s.ctx, fn, item, pipe)
+		}
+		return buffered(func(out chan<- any) error {
+			return call(s.ctx, fn, item, out)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	out := make(chan any)
+	result := make(chan error, 1)
+	go func() {
+		result <- call(ctx, fn, item, out)
+	}()
+
+	timer := option.clock.NewTimer(option.itemTimeout)
+	defer timer.Stop()
+
+	var items []any
+	for {
+		select {
+		case val := <-out:
+			items = append(items, val)
+		case err := <-result:
+			return items, err
+		case <-timer.C():
+			cancel()
+			s.detach(out, result, abandoned)
+			return nil, ErrItemTimeout
+		case <-s.ctx.Done():
+			go abandon(out, result)
+			return nil, s.ctx.Err()
+		case <-s.state.done:
+			go abandon(out, result)
+			return nil, nil
+		}
+	}
+}
+
+// retryable 为fn加上超时和重试，重试耗尽的元素写入死信或返回*ItemError
+func (s Stream) retryable(fn WalkContextFunc, option *rxOptions) WalkErrFunc {
+	limit := option.workers
+	if option.unlimitedWorkers {
+		limit = defaultWorkers
+	}
+	abandoned := make(chan lang.PlaceholderType, limit)
+
+	return func(item any, pipe chan<- any) error {
+		var attempt int
+		var err error
+		for attempt = 1; ; attempt++ {
+			var items []any
+			items, err = s.attempt(fn, item, pipe, option, abandoned)
+			if err == nil {
+				for _, each := range items {
+					if !s.send(pipe, each) {
+						break
+					}
+				}
+				return nil
+			}
+			if s.cancelled() {
+				// 流已被取消或中止，错误由终结操作返回
+				return nil
+			}
+			if attempt >= option.retry.attempts() {
+				break
+			}
+			if !s.wait(option.clock, option.retry.backoff(attempt)) {
+				return nil
+			}
+		}
+
+		itemErr := &ItemError{
+			Item:     item,
+			Attempts: attempt,
+			Err:      err,
+		}
+		if option.deadLetter != nil {
+			option.deadLetter(item, itemErr)
+			return nil
+		}
+
+		return itemErr
+	}
+}
+
+// detach 在后台丢弃超时的尝试之后的输出直到其返回，后台的尝试达到abandoned的容量时等待其中一个返回
+func (s Stream) detach(out <-chan any, result <-chan error, abandoned chan lang.PlaceholderType) {
+	select {
+	case abandoned <- lang.Placeholder:
+		go func() {
+			defer func() {
+				<-abandoned
+			}()
+			abandon(out, result)
+		}()
+	case <-s.ctx.Done():
+		go abandon(out, result)
+	case <-s.state.done:
+		go abandon(out, result)
+	}
+}
+
+// abandon 丢弃已放弃的尝试之后的输出，直到其返回，以免执行fn的goroutine阻塞
+func abandon(out <-chan any, result <-chan error) {
+	for {
+		select {
+		case <-out:
+		case <-result:
+			return
+		}
+	}
+}
+
+// buffered 在当前goroutine中执行run，并缓存其写入out的输出
+func buffered(run func(out chan<- any) error) ([]any, error) {
+	out := make(chan any)
+	done := make(chan []any)
+	go func() {
+		var items []any
+		for val := range out {
+			items = append(items, val)
+		}
+		done <- items
+	}()
+
+	err := run(out)
+	close(out)
+	return <-done, err
+}
+
+// call 执行fn，并将fn中的panic转换为PanicError返回
+func call(ctx context.Context, fn WalkContextFunc, item any, pipe chan<- any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{
+				Value: r,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	return fn(ctx, item, pipe)
+}
@@ -0,0 +1,192 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithItemTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+
+	var lock sync.Mutex
+	var dead []any
+	start := time.Now()
+	items, err := Just(1, 2, 3, 4).Map(func(item any) any {
+		if item.(int) == 2 {
+			<-hang
+		}
+		return item
+	}, WithWorkers(1), WithItemTimeout(50*time.Millisecond), WithDeadLetter(func(item any, err error) {
+		if !errors.Is(err, ErrItemTimeout) {
+			t.Errorf("expect ErrItemTimeout, got %v", err)
+		}
+		lock.Lock()
+		dead = append(dead, item)
+		lock.Unlock()
+	})).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("hung item held the worker for %v", elapsed)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].(int) < items[j].(int)
+	})
+	if len(items) != 3 || items[0] != 1 || items[1] != 3 || items[2] != 4 {
+		t.Fatalf("unexpected items %v", items)
+	}
+	if len(dead) != 1 || dead[0] != 2 {
+		t.Fatalf("unexpected dead letters %v", dead)
+	}
+}
+
+func TestMapContextTimeout(t *testing.T) {
+	var returned int32
+	items, err := Just(1, 2, 3).MapContext(func(ctx context.Context, item any) (any, error) {
+		if item.(int) == 2 {
+			<-ctx.Done()
+			atomic.AddInt32(&returned, 1)
+			return nil, ctx.Err()
+		}
+		return item, nil
+	}, WithWorkers(1), WithItemTimeout(20*time.Millisecond), WithDeadLetter(func(item any, err error) {})).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	// 超时的尝试收到ctx取消后返回，不会一直运行
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&returned) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out attempt was not cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithItemTimeoutAbandonedLimit(t *testing.T) {
+	hang := make(chan struct{})
+	var running, peak int32
+	done := make(chan error)
+	go func() {
+		done <- Just(1, 2, 3, 4, 5).Walk(func(item any, pipe chan<- any) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			<-hang
+			atomic.AddInt32(&running, -1)
+		}, WithWorkers(1), WithItemTimeout(10*time.Millisecond), WithDeadLetter(func(item any, err error) {})).Done()
+	}()
+
+	// 一个worker的阶段最多一个尝试在后台运行，加上worker中正在执行的尝试共两个
+	time.Sleep(100 * time.Millisecond)
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Fatalf("expect 2 concurrent attempts, got %d", p)
+	}
+	close(hang)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Fatalf("expect at most 2 concurrent attempts, got %d", p)
+	}
+}
+
+func TestWithDeadLetterUnbuffered(t *testing.T) {
+	release := make(chan struct{})
+	out := consume(Just(1).Walk(func(item any, pipe chan<- any) {
+		pipe <- item
+		<-release
+	}, WithDeadLetter(func(item any, err error) {})))
+
+	// 只设置死信时输出直接写入下游，不等fn返回
+	select {
+	case item := <-out:
+		if item != 1 {
+			t.Fatalf("unexpected item %v", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("output was buffered until fn returned")
+	}
+	close(release)
+	for range out {
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	var calls int32
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}
+
+	items, err := Just(1).WalkErr(func(item any, pipe chan<- any) error {
+		pipe <- "partial"
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	}, WithRetry(policy)).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 失败的尝试的输出不会写入下游
+	if len(items) != 1 || calls != 3 {
+		t.Fatalf("expect 1 item after 3 calls, got %v after %d calls", items, calls)
+	}
+
+	errBad := errors.New("bad")
+	err = Just(1).MapErr(func(item any) (any, error) {
+		return nil, errBad
+	}, WithRetry(policy)).Done()
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) || itemErr.Attempts != 3 || !errors.Is(err, errBad) {
+		t.Fatalf("expect ItemError after 3 attempts, got %v", err)
+	}
+}
+
+func TestWithRetryPanic(t *testing.T) {
+	var calls int32
+	err := Just(1).Walk(func(item any, pipe chan<- any) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("boom")
+		}
+	}, WithRetry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})).Done()
+	if err != nil || calls != 2 {
+		t.Fatalf("expect success on second attempt, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     30 * time.Millisecond,
+	}
+	for attempt, expect := range []time.Duration{10, 20, 30, 30} {
+		if d := policy.backoff(attempt + 1); d != expect*time.Millisecond {
+			t.Fatalf("attempt %d: expect %v, got %v", attempt+1, expect*time.Millisecond, d)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := policy.backoff(1); d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("jittered backoff out of range: %v", d)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package fx
+
+import (
+	"context"
+	"time"
+)
+
+// After 构建在d之后写入当时时间并结束的流，ctx取消后流提前结束，可以通过WithClock替换时钟
+func After(ctx context.Context, d time.Duration, opts ...Option) Stream {
+	var fired bool
+	return schedule(ctx, buildOptions(opts...).clock, func(prev, now time.Time) (time.Time, bool) {
+		if fired {
+			return time.Time{}, false
+		}
+
+		fired = true
+		return prev.Add(d), true
+	})
+}
+
+// Cron 构建按cron表达式定时写入计划执行时间的流，直到ctx取消，表达式按时钟当前时间的时区计算，
+// 支持标准的5字段格式和@hourly、@daily等描述符，可以通过WithClock替换时钟
+func Cron(ctx context.Context, spec string, opts ...Option) (Stream, error) {
+	sched, err := parseCron(spec)
+	if err != nil {
+		return Stream{}, err
+	}
+
+	return schedule(ctx, buildOptions(opts...).clock, func(prev, now time.Time) (time.Time, bool) {
+		if prev.After(now) {
+			now = prev
+		}
+
+		next := sched.next(now)
+		return next, !next.IsZero()
+	}), nil
+}
+
+// Interval 构建每隔d写入一次计划执行时间的流，直到ctx取消，可以通过WithClock替换时钟，
+// 与time.Ticker相同，下游处理较慢时跳过错过的时间点，而不是连续补发
+func Interval(ctx context.Context, d time.Duration, opts ...Option) Stream {
+	if d <= 0 {
+		panic("d should be greater than 0")
+	}
+
+	return schedule(ctx, buildOptions(opts...).clock, func(prev, now time.Time) (time.Time, bool) {
+		next := prev.Add(d)
+		if now.After(next) {
+			next = next.Add((now.Sub(next)/d + 1) * d)
+		}
+
+		return next, true
+	})
+}
+
+// schedule 构建按next计算的时间写入元素的流，next根据上一次的计划时间和当前时间返回下一次的计划时间，
+// 返回false时流结束，第一次调用时prev为流创建时的时间
+func schedule(ctx context.Context, clock Clock, next func(prev, now time.Time) (time.Time, bool)) Stream {
+	source := make(chan any)
+	s := newStream(ctx, source)
+	s.stage.op = callerOp(1)
+	prev := clock.Now()
+
+	go func() {
+		defer close(source)
+		defer s.state.recover()
+
+		for {
+			now := clock.Now()
+			at, ok := next(prev, now)
+			if !ok {
+				return
+			}
+			if !s.wait(clock, at.Sub(now)) || !s.send(source, at) {
+				return
+			}
+
+			prev = at
+		}
+	}()
+
+	return s
+}
@@ -0,0 +1,108 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// collectTicks 在后台消费流，通过返回的channel逐个取得元素
+func collectTicks(s Stream) <-chan any {
+	ticks := make(chan any)
+	go func() {
+		defer close(ticks)
+		_ = s.ForEach(func(item any) {
+			ticks <- item
+		})
+	}()
+
+	return ticks
+}
+
+func TestInterval(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := collectTicks(Interval(ctx, time.Second, WithClock(clock)))
+
+	for i := 1; i <= 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		if tick := <-ticks; !tick.(time.Time).Equal(start.Add(time.Duration(i) * time.Second)) {
+			t.Fatalf("unexpected tick %v", tick)
+		}
+	}
+
+	// 一次推进多个周期时只输出一次，并跳过错过的时间点
+	clock.BlockUntil(1)
+	clock.Advance(2500 * time.Millisecond)
+	if tick := <-ticks; !tick.(time.Time).Equal(start.Add(4 * time.Second)) {
+		t.Fatalf("unexpected tick %v", tick)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	if tick := <-ticks; !tick.(time.Time).Equal(start.Add(6 * time.Second)) {
+		t.Fatalf("unexpected tick %v", tick)
+	}
+
+	cancel()
+	for range ticks {
+	}
+}
+
+func TestAfter(t *testing.T) {
+	start := time.Now()
+	clock := NewManualClock(start)
+	ticks := collectTicks(After(context.Background(), time.Minute, WithClock(clock)))
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	if tick := <-ticks; !tick.(time.Time).Equal(start.Add(time.Minute)) {
+		t.Fatalf("unexpected tick %v", tick)
+	}
+	if _, ok := <-ticks; ok {
+		t.Fatal("expect After to end after one tick")
+	}
+}
+
+func TestCron(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC)
+	clock := NewManualClock(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, err := Cron(ctx, "*/15 * * * *", WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticks := collectTicks(s)
+
+	for _, expect := range []time.Time{
+		time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+	} {
+		clock.BlockUntil(1)
+		clock.Advance(expect.Sub(clock.Now()))
+		if tick := <-ticks; !tick.(time.Time).Equal(expect) {
+			t.Fatalf("expect %v, got %v", expect, tick)
+		}
+	}
+
+	if _, err = Cron(ctx, "bad"); err == nil {
+		t.Fatal("expect error for invalid spec")
+	}
+}
+
+func TestIntervalCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	count, err := Interval(ctx, 10*time.Millisecond).Count()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	if count == 0 {
+		t.Fatal("expect some ticks before cancel")
+	}
+}
@@ -0,0 +1,402 @@
+package fx
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"os"
+	"sort"
+)
+
+// maxMergeFanIn 外部排序同时归并的临时文件数，超过时先分批归并为更大的临时文件，以限制同时打开的文件数
+const maxMergeFanIn = 64
+
+type (
+	// sortRun 是外部排序中一段已排序的数据
+	sortRun interface {
+		// next 返回下一个元素，读完时返回io.EOF
+		next() (any, error)
+	}
+
+	// fileRun 是写入临时文件的一段已排序数据，只在归并时打开
+	fileRun struct {
+		path    string
+		file    *os.File
+		decoder Decoder
+	}
+
+	// streamRun 是一个已排序的流
+	streamRun struct {
+		stream Stream
+	}
+
+	// memoryRun 是保存在内存中的一段已排序数据
+	memoryRun struct {
+		items []any
+	}
+
+	// runCursor 指向一段数据中当前最小的元素
+	runCursor struct {
+		head any
+		run  sortRun
+	}
+
+	// runHeap 按各段数据当前最小的元素组成的小顶堆，用于k路归并
+	runHeap struct {
+		cursors []*runCursor
+		less    LessFunc
+	}
+
+	// externalSorter 在内存中的元素超过限制时将其排序后写入临时文件，最后对所有文件进行k路归并，
+	// 临时文件多于fanIn个时分多趟归并
+	externalSorter struct {
+		less  LessFunc
+		limit int
+		fanIn int
+		codec Codec
+		dir   string
+		items []any
+		runs  []*fileRun
+	}
+)
+
+// MergeSorted 将多个已按less排好序的流归并为一个整体有序的流，每个流同时只读取一个元素，
+// 相等元素的先后顺序不确定，任意一个流出错时归并后的流同样中止
+func MergeSorted(less LessFunc, streams ...Stream) Stream {
+	if len(streams) == 0 {
+		return Just()
+	}
+
+	s := streams[0]
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+
+		inputs := make([]Stream, 0, len(streams))
+		inputs = append(inputs, s)
+		for _, each := range streams[1:] {
+			inputs = append(inputs, s.adopt(each))
+		}
+		// 归并可能提前结束，清空所有输入以免上游阻塞
+		defer func() {
+			for _, each := range inputs {
+				go drain(each.input())
+			}
+		}()
+		defer s.state.recover()
+
+		h := &runHeap{less: less}
+		for _, input := range inputs {
+			h.advance(&runCursor{run: streamRun{stream: input}})
+		}
+
+		for h.Len() > 0 {
+			cursor := heap.Pop(h).(*runCursor)
+			if !s.send(source, cursor.head) {
+				return
+			}
+			h.advance(cursor)
+		}
+
+		// 其他流有各自的状态，出错时同样中止归并后的流
+		for _, each := range streams[1:] {
+			if err := each.err(); err != nil {
+				s.state.fail(err)
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// WithSpill 使Sort在内存中的元素超过maxInMemory个时，将其排序后通过codec写入临时文件，
+// 最后对所有临时文件进行k路归并，用于排序无法全部放入内存的流；
+// maxInMemory限制的是元素个数而不是字节数，元素大小差异较大时应按最大的元素估算内存占用，
+// 临时文件较多时分多趟归并，每趟最多同时读取64个临时文件
+func WithSpill(maxInMemory int, codec Codec) Option {
+	if maxInMemory < 1 {
+		panic("maxInMemory should be greater than 0")
+	}
+
+	return func(opts *rxOptions) {
+		opts.spillLimit = maxInMemory
+		opts.spillCodec = codec
+	}
+}
+
+// WithSpillDir 指定Sort写入临时文件的目录，默认为os.TempDir()
+func WithSpillDir(dir string) Option {
+	return func(opts *rxOptions) {
+		opts.spillDir = dir
+	}
+}
+
+// sortExternal 使用临时文件对流进行外部排序，临时文件在排序结束、出错或流被取消时删除
+func (s Stream) sortExternal(less LessFunc, option *rxOptions) Stream {
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+		defer s.state.recover()
+
+		sorter := &externalSorter{
+			less:  less,
+			limit: option.spillLimit,
+			fanIn: maxMergeFanIn,
+			codec: option.spillCodec,
+			dir:   option.spillDir,
+		}
+		defer sorter.cleanup()
+
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+			if err := sorter.add(item); err != nil {
+				s.state.fail(err)
+				return
+			}
+		}
+		if s.cancelled() {
+			return
+		}
+
+		if err := sorter.merge(func(item any) bool {
+			return s.send(source, item)
+		}); err != nil {
+			s.state.fail(err)
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// add 加入item，内存中的元素达到限制时写入临时文件
+func (es *externalSorter) add(item any) error {
+	es.items = append(es.items, item)
+	if len(es.items) < es.limit {
+		return nil
+	}
+
+	return es.spill()
+}
+
+// cleanup 关闭并删除所有临时文件
+func (es *externalSorter) cleanup() {
+	for _, run := range es.runs {
+		run.close()
+		os.Remove(run.path)
+	}
+	es.runs = nil
+}
+
+// compact 将最前面的fanIn个临时文件归并为一个新的临时文件，放在最后
+func (es *externalSorter) compact() error {
+	merged := es.runs[:es.fanIn]
+	runs := make([]sortRun, 0, len(merged))
+	for _, run := range merged {
+		if err := run.open(es.codec); err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+
+	if err := es.write(func(encoder Encoder) error {
+		var err error
+		if mergeErr := mergeRuns(es.less, runs, func(item any) bool {
+			err = encoder.Encode(item)
+			return err == nil
+		}); mergeErr != nil {
+			return mergeErr
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+
+	for _, run := range merged {
+		run.close()
+		os.Remove(run.path)
+	}
+	es.runs = append(es.runs[:0], es.runs[es.fanIn:]...)
+	return nil
+}
+
+// merge 对所有临时文件和内存中剩余的元素进行k路归并，按顺序交给emit，emit返回false时停止
+func (es *externalSorter) merge(emit func(item any) bool) error {
+	// 内存中剩余的元素也占一路
+	for len(es.runs) >= es.fanIn {
+		if err := es.compact(); err != nil {
+			return err
+		}
+	}
+
+	es.sortItems()
+	runs := []sortRun{&memoryRun{items: es.items}}
+	for _, run := range es.runs {
+		if err := run.open(es.codec); err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+
+	return mergeRuns(es.less, runs, emit)
+}
+
+// sortItems 对内存中的元素排序
+func (es *externalSorter) sortItems() {
+	sort.Slice(es.items, func(i, j int) bool {
+		return es.less(es.items[i], es.items[j])
+	})
+}
+
+// spill 将内存中的元素排序后写入新的临时文件
+func (es *externalSorter) spill() error {
+	es.sortItems()
+	if err := es.write(func(encoder Encoder) error {
+		for _, item := range es.items {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	es.items = es.items[:0]
+	return nil
+}
+
+// write 创建新的临时文件并通过fn写入，写完后关闭文件，归并时再打开
+func (es *externalSorter) write(fn func(encoder Encoder) error) error {
+	file, err := os.CreateTemp(es.dir, "fx-sort-*")
+	if err != nil {
+		return err
+	}
+	// 先记录下来，出错时也能被cleanup删除
+	run := &fileRun{path: file.Name(), file: file}
+	es.runs = append(es.runs, run)
+
+	writer := bufio.NewWriter(file)
+	if err := fn(es.codec.NewEncoder(writer)); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	return run.close()
+}
+
+func (r *fileRun) next() (any, error) {
+	return r.decoder.Decode()
+}
+
+// open 打开临时文件准备读取
+func (r *fileRun) open(codec Codec) error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.file = file
+	r.decoder = codec.NewDecoder(bufio.NewReader(file))
+	return nil
+}
+
+// close 关闭已打开的临时文件
+func (r *fileRun) close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	r.decoder = nil
+	return err
+}
+
+func (r streamRun) next() (any, error) {
+	item, ok := r.stream.recv()
+	if !ok {
+		return nil, io.EOF
+	}
+
+	return item, nil
+}
+
+func (r *memoryRun) next() (any, error) {
+	if len(r.items) == 0 {
+		return nil, io.EOF
+	}
+
+	item := r.items[0]
+	r.items[0] = nil
+	r.items = r.items[1:]
+	return item, nil
+}
+
+func (h *runHeap) Len() int {
+	return len(h.cursors)
+}
+
+func (h *runHeap) Less(i, j int) bool {
+	return h.less(h.cursors[i].head, h.cursors[j].head)
+}
+
+func (h *runHeap) Swap(i, j int) {
+	h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i]
+}
+
+func (h *runHeap) Push(x any) {
+	h.cursors = append(h.cursors, x.(*runCursor))
+}
+
+func (h *runHeap) Pop() any {
+	n := len(h.cursors)
+	cursor := h.cursors[n-1]
+	h.cursors[n-1] = nil
+	h.cursors = h.cursors[:n-1]
+	return cursor
+}
+
+// advance 读取cursor所在数据段的下一个元素并放回堆中，数据段读完时不再放回
+func (h *runHeap) advance(cursor *runCursor) error {
+	item, err := cursor.run.next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cursor.head = item
+	heap.Push(h, cursor)
+	return nil
+}
+
+// mergeRuns 对runs进行k路归并，按顺序交给emit，emit返回false时停止
+func mergeRuns(less LessFunc, runs []sortRun, emit func(item any) bool) error {
+	h := &runHeap{less: less}
+	for _, run := range runs {
+		if err := h.advance(&runCursor{run: run}); err != nil {
+			return err
+		}
+	}
+
+	for h.Len() > 0 {
+		cursor := heap.Pop(h).(*runCursor)
+		if !emit(cursor.head) {
+			return nil
+		}
+		if err := h.advance(cursor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
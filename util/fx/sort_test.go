@@ -0,0 +1,222 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type sortRecord struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestSortSpill(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		item  func(v int) any
+		key   func(item any) int
+	}{
+		{
+			name:  "gob",
+			codec: GobCodec[int](),
+			item: func(v int) any {
+				return v
+			},
+			key: func(item any) int {
+				return item.(int)
+			},
+		},
+		{
+			name:  "json lines",
+			codec: JSONLinesCodec[sortRecord](),
+			item: func(v int) any {
+				return sortRecord{Id: v, Name: "record"}
+			},
+			key: func(item any) int {
+				return item.(sortRecord).Id
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var result []int
+			err := From(func(source chan<- any) {
+				for _, v := range rand.Perm(1000) {
+					source <- test.item(v)
+				}
+			}).Sort(func(a, b any) bool {
+				return test.key(a) < test.key(b)
+			}, WithSpill(64, test.codec), WithSpillDir(dir)).ForEach(func(item any) {
+				result = append(result, test.key(item))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result) != 1000 {
+				t.Fatalf("expect 1000 items, got %d", len(result))
+			}
+			for i, v := range result {
+				if v != i {
+					t.Fatalf("expect %d at %d, got %d", i, i, v)
+				}
+			}
+			assertEmptyDir(t, dir)
+		})
+	}
+}
+
+func TestSortSpillFanIn(t *testing.T) {
+	dir := t.TempDir()
+	sorter := &externalSorter{
+		less: func(a, b any) bool {
+			return a.(int) < b.(int)
+		},
+		limit: 10,
+		fanIn: 3,
+		codec: GobCodec[int](),
+		dir:   dir,
+	}
+	defer sorter.cleanup()
+
+	for _, v := range rand.Perm(205) {
+		if err := sorter.add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sorter.runs) != 20 {
+		t.Fatalf("expect 20 temp files, got %d", len(sorter.runs))
+	}
+
+	var result []int
+	if err := sorter.merge(func(item any) bool {
+		// 分多趟归并后，最后一趟归并的临时文件数小于fanIn
+		if len(sorter.runs) >= sorter.fanIn {
+			t.Fatalf("expect less than %d temp files in final merge, got %d", sorter.fanIn, len(sorter.runs))
+		}
+		result = append(result, item.(int))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 205 {
+		t.Fatalf("expect 205 items, got %d", len(result))
+	}
+	for i, v := range result {
+		if v != i {
+			t.Fatalf("expect %d at %d, got %d", i, i, v)
+		}
+	}
+
+	sorter.cleanup()
+	assertEmptyDir(t, dir)
+}
+
+func TestSortSpillCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	var count int
+	err := FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		for _, v := range rand.Perm(1000) {
+			select {
+			case source <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}).Sort(func(a, b any) bool {
+		return a.(int) < b.(int)
+	}, WithSpill(100, GobCodec[int]()), WithSpillDir(dir)).ForEach(func(item any) {
+		count++
+		if count == 10 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+	assertEmptyDir(t, dir)
+}
+
+func TestSortSpillEncodeError(t *testing.T) {
+	dir := t.TempDir()
+	// gob无法编码channel
+	err := Just(make(chan int), make(chan int)).Sort(func(a, b any) bool {
+		return false
+	}, WithSpill(1, GobCodec[chan int]()), WithSpillDir(dir)).Done()
+	if err == nil {
+		t.Fatal("expect encode error")
+	}
+	assertEmptyDir(t, dir)
+}
+
+// assertEmptyDir 临时文件在排序的goroutine退出时删除，等待一段时间后检查
+func assertEmptyDir(t *testing.T, dir string) {
+	for i := 0; i < 100; i++ {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("temp files in %s are not removed", dir)
+}
+
+func TestMergeSorted(t *testing.T) {
+	less := func(a, b any) bool {
+		return a.(int) < b.(int)
+	}
+	odd := func(item any) bool {
+		return item.(int)%2 == 1
+	}
+
+	items, err := MergeSorted(less,
+		Just(1, 4, 7, 10),
+		Just(),
+		Just(2, 2, 9),
+		Just(0, 3, 5, 6, 8).Filter(odd, WithWorkers(1)),
+	).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []any{1, 2, 2, 3, 4, 5, 7, 9, 10}) {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	head, err := MergeSorted(less, Just(1, 3, 5), Just(2, 4, 6)).Head(3).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(head, []any{1, 2, 3}) {
+		t.Fatalf("unexpected head %v", head)
+	}
+
+	if count, err := MergeSorted(less).Count(); err != nil || count != 0 {
+		t.Fatalf("expect empty stream, got %d, %v", count, err)
+	}
+}
+
+func TestMergeSortedError(t *testing.T) {
+	errBad := errors.New("bad shard")
+	err := MergeSorted(func(a, b any) bool {
+		return a.(int) < b.(int)
+	}, Just(1, 2, 3), Just(4, 5).MapErr(func(item any) (any, error) {
+		return nil, errBad
+	})).Done()
+	if !errors.Is(err, errBad) {
+		t.Fatalf("expect errBad, got %v", err)
+	}
+}
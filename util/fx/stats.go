@@ -0,0 +1,311 @@
+package fx
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// hllPrecision HyperLogLog使用2^14个寄存器，标准误差约0.8%
+	hllPrecision = 14
+	// tdigestCompression t-digest的压缩参数，越大越精确，占用的内存也越多
+	tdigestCompression = 100
+)
+
+type (
+	// Statistics 是Stats计算出的统计量
+	Statistics struct {
+		Count int64
+		Mean  float64
+		Min   float64
+		Max   float64
+		// m2 是与均值之差的平方和，用于计算方差
+		m2 float64
+	}
+
+	// centroid 是t-digest中的一个质心，代表weight个均值为mean的数值
+	centroid struct {
+		mean   float64
+		weight float64
+	}
+
+	// hyperLogLog 用于估算不同元素的个数，内存固定为2^hllPrecision字节
+	hyperLogLog struct {
+		registers []uint8
+	}
+
+	// tdigest 用于估算分位数，只保存有限个质心，在两端的分位数上误差更小
+	tdigest struct {
+		centroids []centroid
+		buffer    []float64
+		count     float64
+		min       float64
+		max       float64
+	}
+)
+
+// Cardinality 使用HyperLogLog估算流中fn返回的不同key的个数，标准误差约0.8%，内存固定为16KB
+func (s Stream) Cardinality(fn KeyFunc) (uint64, error) {
+	hll := newHyperLogLog()
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+		hll.add(fn(item))
+	}
+
+	return hll.estimate(), s.complete()
+}
+
+// Quantiles 使用t-digest估算流中fn的各个分位数，qs的取值范围为[0, 1]，结果与qs一一对应，
+// 只保存有限个质心，不需要对所有元素排序，流为空时结果为NaN
+func (s Stream) Quantiles(fn ValueFunc, qs ...float64) ([]float64, error) {
+	for _, q := range qs {
+		if q < 0 || q > 1 {
+			panic("quantile should be in range [0, 1]")
+		}
+	}
+
+	digest := newTDigest()
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+		digest.add(fn(item))
+	}
+
+	result := make([]float64, len(qs))
+	for i, q := range qs {
+		result[i] = digest.quantile(q)
+	}
+
+	return result, s.complete()
+}
+
+// ReservoirSample 使用蓄水池抽样从流中等概率的抽取k个元素，元素不足k个时返回所有元素
+func (s Stream) ReservoirSample(k int) ([]any, error) {
+	if k < 1 {
+		panic("k should be greater than 0")
+	}
+
+	var samples []any
+	var seen int64
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+
+		seen++
+		if len(samples) < k {
+			samples = append(samples, item)
+		} else if i := rand.Int63n(seen); i < int64(k) {
+			samples[i] = item
+		}
+	}
+
+	return samples, s.complete()
+}
+
+// Stats 使用Welford算法单遍计算流中fn的个数、均值、方差、最小值和最大值
+func (s Stream) Stats(fn ValueFunc) (Statistics, error) {
+	var stats Statistics
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
+		stats.add(fn(item))
+	}
+
+	return stats, s.complete()
+}
+
+// SampleVariance 返回样本方差
+func (st Statistics) SampleVariance() float64 {
+	if st.Count < 2 {
+		return 0
+	}
+
+	return st.m2 / float64(st.Count-1)
+}
+
+// StdDev 返回总体标准差
+func (st Statistics) StdDev() float64 {
+	return math.Sqrt(st.Variance())
+}
+
+// Variance 返回总体方差
+func (st Statistics) Variance() float64 {
+	if st.Count == 0 {
+		return 0
+	}
+
+	return st.m2 / float64(st.Count)
+}
+
+func (st *Statistics) add(v float64) {
+	if st.Count == 0 || v < st.Min {
+		st.Min = v
+	}
+	if st.Count == 0 || v > st.Max {
+		st.Max = v
+	}
+
+	st.Count++
+	delta := v - st.Mean
+	st.Mean += delta / float64(st.Count)
+	st.m2 += delta * (v - st.Mean)
+}
+
+// newHyperLogLog 返回一个hyperLogLog
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{
+		registers: make([]uint8, 1<<hllPrecision),
+	}
+}
+
+// add 用哈希值的高位选择寄存器，记录剩余位中第一个1出现的最大位置
+func (h *hyperLogLog) add(key any) {
+	hash, _ := hashKey(key)
+	hash = fmix64(hash)
+	index := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// estimate 返回估算的不同元素个数，基数较小时使用线性计数修正
+func (h *hyperLogLog) estimate() uint64 {
+	m := float64(len(h.registers))
+	var sum float64
+	var zeros int
+	for _, rank := range h.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+// newTDigest 返回一个tdigest
+func newTDigest() *tdigest {
+	return &tdigest{
+		min: math.Inf(1),
+		max: math.Inf(-1),
+	}
+}
+
+func (td *tdigest) add(v float64) {
+	td.buffer = append(td.buffer, v)
+	td.count++
+	if v < td.min {
+		td.min = v
+	}
+	if v > td.max {
+		td.max = v
+	}
+	if len(td.buffer) >= tdigestCompression*5 {
+		td.compress()
+	}
+}
+
+// compress 将缓冲的数值与已有质心按均值排序后合并，靠近两端的质心允许的权重更小
+func (td *tdigest) compress() {
+	if len(td.buffer) == 0 {
+		return
+	}
+
+	all := make([]centroid, 0, len(td.centroids)+len(td.buffer))
+	all = append(all, td.centroids...)
+	for _, v := range td.buffer {
+		all = append(all, centroid{mean: v, weight: 1})
+	}
+	td.buffer = td.buffer[:0]
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+
+	merged := all[:1]
+	// 当前质心之前的累计权重
+	var before float64
+	for _, next := range all[1:] {
+		last := &merged[len(merged)-1]
+		if tdigestScale(td.count, before+last.weight+next.weight)-tdigestScale(td.count, before) <= 1 {
+			last.weight += next.weight
+			last.mean += (next.mean - last.mean) * next.weight / last.weight
+		} else {
+			before += last.weight
+			merged = append(merged, next)
+		}
+	}
+	td.centroids = append([]centroid(nil), merged...)
+}
+
+// quantile 在相邻质心之间线性插值估算分位数q
+func (td *tdigest) quantile(q float64) float64 {
+	td.compress()
+	if len(td.centroids) == 0 {
+		return math.NaN()
+	}
+	if len(td.centroids) == 1 || q <= 0 {
+		return td.min
+	}
+	if q >= 1 {
+		return td.max
+	}
+
+	// 每个质心的权重中心位于其累计权重的中点
+	target := q * td.count
+	var before float64
+	for i, c := range td.centroids {
+		center := before + c.weight/2
+		if target < center {
+			if i == 0 {
+				return td.min + (c.mean-td.min)*target/center
+			}
+			prev := td.centroids[i-1]
+			prevCenter := before - prev.weight/2
+			return prev.mean + (c.mean-prev.mean)*(target-prevCenter)/(center-prevCenter)
+		}
+		before += c.weight
+	}
+
+	last := td.centroids[len(td.centroids)-1]
+	lastCenter := td.count - last.weight/2
+	return last.mean + (td.max-last.mean)*(target-lastCenter)/(td.count-lastCenter)
+}
+
+// tdigestScale 是t-digest的k1尺度函数，将累计权重映射到质心序号上
+func tdigestScale(total, cumulative float64) float64 {
+	q := cumulative / total
+	if q > 1 {
+		q = 1
+	}
+
+	return tdigestCompression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// fmix64 使用murmur3的fmix64打散哈希值，fnv的高位分布较差，不能直接用于选择寄存器
+func fmix64(hash uint64) uint64 {
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+
+	return hash
+}
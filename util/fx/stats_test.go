@@ -0,0 +1,100 @@
+package fx
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	stats, err := Just(2, 4, 4, 4, 5, 5, 7, 9).Stats(func(item any) float64 {
+		return float64(item.(int))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Count != 8 || stats.Mean != 5 || stats.Min != 2 || stats.Max != 9 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.Variance() != 4 || stats.StdDev() != 2 {
+		t.Fatalf("expect variance 4, got %v", stats.Variance())
+	}
+	if math.Abs(stats.SampleVariance()-32.0/7) > 1e-9 {
+		t.Fatalf("expect sample variance %v, got %v", 32.0/7, stats.SampleVariance())
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	const n = 100000
+	result, err := From(func(source chan<- any) {
+		for _, v := range rand.Perm(n) {
+			source <- v
+		}
+	}).Quantiles(func(item any) float64 {
+		return float64(item.(int))
+	}, 0, 0.5, 0.99, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expects := []float64{0, n * 0.5, n * 0.99, n - 1}
+	for i, expect := range expects {
+		if math.Abs(result[i]-expect) > n*0.005 {
+			t.Fatalf("quantile %d expect %v, got %v", i, expect, result[i])
+		}
+	}
+
+	empty, err := Just().Quantiles(func(item any) float64 {
+		return 0
+	}, 0.5)
+	if err != nil || !math.IsNaN(empty[0]) {
+		t.Fatalf("expect NaN, got %v, %v", empty, err)
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	tests := []int{10, 1000, 100000}
+	for _, n := range tests {
+		estimate, err := From(func(source chan<- any) {
+			// 每个元素重复3次
+			for i := 0; i < n*3; i++ {
+				source <- i % n
+			}
+		}).Cardinality(func(item any) any {
+			return item
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(float64(estimate)-float64(n)) > float64(n)*0.03 {
+			t.Fatalf("expect about %d, got %d", n, estimate)
+		}
+	}
+}
+
+func TestReservoirSample(t *testing.T) {
+	samples, err := From(func(source chan<- any) {
+		for i := 0; i < 1000; i++ {
+			source <- i
+		}
+	}).ReservoirSample(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 10 {
+		t.Fatalf("expect 10 samples, got %d", len(samples))
+	}
+
+	seen := make(map[any]bool)
+	for _, item := range samples {
+		if seen[item] || item.(int) < 0 || item.(int) >= 1000 {
+			t.Fatalf("unexpected sample %v", item)
+		}
+		seen[item] = true
+	}
+
+	samples, err = Just(1, 2).ReservoirSample(10)
+	if err != nil || len(samples) != 2 {
+		t.Fatalf("expect all items, got %v, %v", samples, err)
+	}
+}
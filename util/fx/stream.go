@@ -1,11 +1,14 @@
 package fx
 
 import (
+	"context"
+	"fmt"
 	"just4play/util/collection"
 	"just4play/util/lang"
 	"just4play/util/thread"
 	"sort"
 	"sync"
+	"time"
 )
 
 const (
@@ -15,16 +18,36 @@ const (
 
 type (
 	rxOptions struct {
-		unlimitedWorkers bool //是否使用无限数量的工作者
-		workers          int  //指定的工作者数量
+		unlimitedWorkers bool                //是否使用无限数量的工作者
+		workers          int                 //指定的工作者数量
+		collectErrors    bool                //出错时是否继续处理并收集所有错误，默认第一个错误即中止整条流
+		ordered          bool                //是否按输入顺序输出结果
+		clock            Clock               //基于时间的操作所使用的时钟
+		keyLess          LessFunc            //JoinBy中key的比较函数，设置后使用归并连接
+		spillLimit       int                 //Sort在内存中最多保存的元素个数，超过时写入临时文件
+		spillCodec       Codec               //Sort写入临时文件时的编解码方式
+		spillDir         string              //Sort写入临时文件的目录
+		newDeduper       func(Clock) Deduper //Distinct使用的去重方式，默认记录所有出现过的key
+		backpressure     BackpressurePolicy  //Partition和Tee中下游缓冲已满时的处理方式
+		fanOutBuffer     int                 //Partition和Tee中每个下游的缓冲大小
+		itemTimeout      time.Duration       //Walk中处理单个元素的超时时间
+		retry            *RetryPolicy        //Walk中元素处理失败时的重试方式
+		deadLetter       DeadLetterFunc      //Walk中重试耗尽的元素的接收方
+		observer         Observer            //整条流的各个阶段的观察者
+		adaptiveMin      int                 //Walk自动调整并发数时的最小并发数
+		adaptiveMax      int                 //Walk自动调整并发数时的最大并发数，为0时不自动调整
 	}
 
 	// FilterFunc 用于过滤流中的元素。它接收一个元素并返回一个布尔值，用于指示是否保留该元素
 	FilterFunc func(item any) bool
+	// FlatMapFunc 用于将流中的每个元素展开为多个元素。它接收一个元素作为参数，并返回展开后的元素
+	FlatMapFunc func(item any) []any
 	// ForAllFunc 用于处理流中的所有元素。它接收一个通道，可以从该通道中接收元素进行处理
 	ForAllFunc func(pipe <-chan any)
 	// ForEachFunc 用于处理流中的每个元素。它接收一个元素作为参数
 	ForEachFunc func(item any)
+	// ContextGenerateFunc 与GenerateFunc相同，但接收流的ctx，ctx取消或流被中止后应尽快返回
+	ContextGenerateFunc func(ctx context.Context, source chan<- any)
 	// GenerateFunc 用于向流中发送元素。它接收一个通道，可以向该通道发送元素
 	GenerateFunc func(source chan<- any)
 	// KeyFunc 用于为流中的元素生成键。它接收一个元素作为参数，并返回一个键
@@ -33,18 +56,38 @@ type (
 	LessFunc func(a, b any) bool
 	// MapFunc 用于将流中的每个元素映射到另一个对象。它接收一个元素作为参数，并返回一个映射后的元素
 	MapFunc func(item any) any
+	// MapContextFunc 与MapErrFunc相同，但接收ctx，设置WithItemTimeout时ctx在超时后取消
+	MapContextFunc func(ctx context.Context, item any) (any, error)
+	// MapErrFunc 与MapFunc相同，但可以返回错误，返回错误时该元素不会写入下游
+	MapErrFunc func(item any) (any, error)
 	// Option 用于自定义流的选项。它接收一个 rxOptions 指针作为参数
 	Option func(opts *rxOptions)
 	// ParallelFunc 用于并行处理流中的元素。它接收一个元素作为参数
 	ParallelFunc func(item any)
 	// ReduceFunc 用于将流中的所有元素进行归约。它接收一个通道作为参数，并返回一个归约后的结果和可能的错误
 	ReduceFunc func(pipe <-chan any) (any, error)
+	// ScanFunc 用于累加流中的元素。它接收当前的累加结果和一个元素作为参数，并返回新的累加结果
+	ScanFunc func(acc, item any) any
 	// WalkFunc 用于遍历流中的所有元素。它接收一个元素和一个通道作为参数，可以将处理后的元素发送到通道中
 	WalkFunc func(item any, pipe chan<- any)
+	// WalkContextFunc 与WalkErrFunc相同，但接收ctx，设置WithItemTimeout时ctx在超时后取消
+	WalkContextFunc func(ctx context.Context, item any, pipe chan<- any) error
+	// WalkErrFunc 与WalkFunc相同，但可以返回错误
+	WalkErrFunc func(item any, pipe chan<- any) error
+	// ZipFunc 用于合并两个流中相同位置的元素。它接收两个元素作为参数，并返回合并后的元素
+	ZipFunc func(a, b any) any
 
 	// Stream 定义了一个流，它包含一个源通道，用于从源通道中接收元素进行处理
+	// ctx和state在流的所有阶段间共享，ctx取消或某个阶段出错后各阶段停止处理并尽快退出，stage为产生该流的阶段
 	Stream struct {
 		source <-chan any
+		ctx    context.Context
+		state  *streamState
+		stage  *stage
+		// plan 不为nil时当前流为尚未启动的计划阶段，source在第一次读取时由plan产生
+		plan *plan
+		// drainSource 为true时source的写入方不感知取消，流被取消后需要清空source使其退出
+		drainSource bool
 	}
 )
 
@@ -56,14 +99,38 @@ func Concat(s Stream, others ...Stream) Stream {
 // From 通过From函数构建流并返回Stream，流数据通过channel进行存储
 func From(generate GenerateFunc) Stream {
 	source := make(chan any)
+	s := newStream(context.Background(), source)
+	// generate不感知取消，流被中止后只能清空source使其退出
+	s.drainSource = true
 
-	thread.SafeGoroutine(func() {
+	go func() {
 		defer close(source)
+		defer s.state.recover()
 		// 构造流数据写入channel
 		generate(source)
-	})
+	}()
 
-	return Range(source)
+	return s
+}
+
+// FromContext 与From相同，但流绑定了ctx，ctx取消后各阶段goroutine停止处理，终结操作返回ctx.Err()
+// generate接收的ctx在流绑定的ctx取消或流被中止时取消，写入source时应同时监听ctx.Done()并及时返回，
+// 流被取消后不会再清空source，未监听ctx的generate会一直阻塞在写入上
+func FromContext(ctx context.Context, generate ContextGenerateFunc) Stream {
+	source := make(chan any)
+	s := newStream(ctx, source)
+
+	go func() {
+		defer close(source)
+		defer s.state.recover()
+
+		genCtx, cancel := s.sourceContext()
+		defer cancel()
+		// 构造流数据写入channel
+		generate(genCtx, source)
+	}()
+
+	return s
 }
 
 // Just 批量传入元素构建stream流
@@ -79,33 +146,45 @@ func Just(items ...any) Stream {
 
 // Range converts the given channel to a Stream.
 func Range(source <-chan any) Stream {
-	return Stream{
-		source: source,
-	}
+	s := newStream(context.Background(), source)
+	// source由调用方写入，流被中止后清空source以免写入方阻塞
+	s.drainSource = true
+
+	return s
 }
 
 // AllMach 一个stream中的所有元素是否都满足给定的条件
 func (s Stream) AllMach(predicate func(item any) bool) bool {
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		if !predicate(item) {
 			// make sure the former goroutine not block, and current func returns fast.
-			go drain(s.source)
+			go drain(s.input())
 			return false
 		}
 	}
+	s.release()
 
 	return true
 }
 
 // AnyMach 一个Stream中是否存在任何满足给定条件的元素
 func (s Stream) AnyMach(predicate func(item any) bool) bool {
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		if predicate(item) {
 			// make sure the former goroutine not block, and current func returns fast.
-			go drain(s.source)
+			go drain(s.input())
 			return true
 		}
 	}
+	s.release()
 
 	return false
 }
@@ -119,13 +198,11 @@ func (s Stream) Buffer(n int) Stream {
 
 	source := make(chan any, n)
 	go func() {
-		for item := range s.source {
-			source <- item
-		}
-		close(source)
+		defer close(source)
+		s.pipeTo(source)
 	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
 // Concat 合并流
@@ -135,16 +212,20 @@ func (s Stream) Concat(others ...Stream) Stream {
 	go func() {
 		group := thread.NewRoutineGroup()
 		group.Run(func() {
-			for item := range s.source {
-				source <- item
-			}
+			s.pipeTo(source)
 		})
 
 		for _, each := range others {
 			each := each
 			group.Run(func() {
-				for item := range each.source {
-					source <- item
+				s.adopt(each).pipeTo(source)
+				// 合并后的流被取消时each不会感知，清空以免其上游阻塞
+				if s.cancelled() {
+					go drain(each.input())
+				}
+				// 合并进来的流出错时同样中止合并后的流
+				if err := each.err(); err != nil {
+					s.state.fail(err)
 				}
 			})
 		}
@@ -153,45 +234,72 @@ func (s Stream) Concat(others ...Stream) Stream {
 		close(source)
 	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
-// Count 统计流中元素个数
-func (s Stream) Count() (count int) {
-	for range s.source {
+// Count 统计流中元素个数，流被取消或出错时返回对应的错误
+func (s Stream) Count() (count int, err error) {
+	for {
+		if _, ok := s.recv(); !ok {
+			break
+		}
 		count++
 	}
-	return
+
+	return count, s.complete()
 }
 
 // Distinct distinct对流中元素进行去重，去重在业务开发中比较常用，经常需要对用户id等做去重操作
-func (s Stream) Distinct(fn KeyFunc) Stream {
+// 默认记录所有出现过的key，对于不会结束的流可以通过WithLRUDedup或WithBloomDedup限制内存
+func (s Stream) Distinct(fn KeyFunc, opts ...Option) Stream {
+	option := s.options(opts...)
 	source := make(chan any)
 
-	thread.SafeGoroutine(func() {
+	go func() {
 		defer close(source)
+		defer s.release()
+		defer s.state.recover()
 		// 通过key进行去重，相同key只保留一个
-		keys := make(map[any]lang.PlaceholderType)
-		for item := range s.source {
-			key := fn(item)
+		var deduper Deduper = newMapDeduper()
+		if option.newDeduper != nil {
+			deduper = option.newDeduper(option.clock)
+		}
+		for {
+			item, ok := s.recv()
+			if !ok {
+				return
+			}
 			// key存在则不保留
-			if _, ok := keys[key]; !ok {
-				source <- item
-				keys[key] = lang.Placeholder
+			if !deduper.Seen(fn(item)) {
+				if !s.send(source, item) {
+					return
+				}
 			}
 		}
-	})
+	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
-// Done 是等待所有上游操作完成
-func (s Stream) Done() {
-	drain(s.source)
+// Done 是等待所有上游操作完成，流被取消或出错时返回对应的错误
+func (s Stream) Done() error {
+	for {
+		if _, ok := s.recv(); !ok {
+			break
+		}
+	}
+
+	return s.complete()
 }
 
-// Filter 过滤不满足条件的item
+// Filter 过滤不满足条件的item，与相邻且并发设置相同的Filter、Map、MapErr合并为一个阶段，在流被读取时才启动
 func (s Stream) Filter(fn FilterFunc, opts ...Option) Stream {
+	if option := s.options(opts...); option.fusible() {
+		return s.fuse("Filter", option, func(item any) (any, bool) {
+			return item, fn(item)
+		})
+	}
+
 	return s.Walk(func(item any, pipe chan<- any) {
 		if fn(item) {
 			pipe <- item
@@ -199,52 +307,78 @@ func (s Stream) Filter(fn FilterFunc, opts ...Option) Stream {
 	}, opts...)
 }
 
+// FlatMap 将每个元素展开为多个元素写入新流，可以通过WithWorkers设置并发数
+func (s Stream) FlatMap(fn FlatMapFunc, opts ...Option) Stream {
+	return s.Walk(func(item any, pipe chan<- any) {
+		for _, each := range fn(item) {
+			pipe <- each
+		}
+	}, opts...)
+}
+
 // First 返回第一个元素
 func (s Stream) First() any {
-	for item := range s.source {
+	if item, ok := s.recv(); ok {
 		// make sure the former goroutine not block, and current func returns fast.
-		go drain(s.source)
+		go drain(s.input())
 		return item
 	}
 
+	s.release()
 	return nil
 }
 
 // ForAll handles the streaming elements from the source and no later streams.
-func (s Stream) ForAll(fn ForAllFunc) {
-	fn(s.source)
+// 流被取消或出错时返回对应的错误
+func (s Stream) ForAll(fn ForAllFunc) error {
+	fn(s.input())
 	// avoid goroutine leak on fn not consuming all items.
-	go drain(s.source)
+	go drain(s.input())
+
+	return s.err()
 }
 
-// ForEach 遍历流中所有元素
-func (s Stream) ForEach(fn ForEachFunc) {
-	for item := range s.source {
+// ForEach 遍历流中所有元素，流被取消或出错时停止遍历并返回对应的错误
+func (s Stream) ForEach(fn ForEachFunc) error {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		fn(item)
 	}
+
+	return s.complete()
 }
 
 // Group Group对流数据进行分组，需定义分组的key，数据分组后以slice存入channel:
 func (s Stream) Group(fn KeyFunc) Stream {
 	// 定义分组存储map
 	groups := make(map[any][]any)
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		// 用户自定义分组key
 		key := fn(item)
 		// key相同分到一组
 		groups[key] = append(groups[key], item)
 	}
+	s.release()
 
 	source := make(chan any)
 	go func() {
+		defer close(source)
 		for _, group := range groups {
 			// 相同key的一组数据写入到channel
-			source <- group
+			if !s.send(source, group) {
+				return
+			}
 		}
-		close(source)
 	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
 // Head 取出前n个item，返回新stream
@@ -256,51 +390,155 @@ func (s Stream) Head(n int64) Stream {
 	source := make(chan any)
 
 	go func() {
-		for item := range s.source {
+		defer close(source)
+		for n > 0 {
+			item, ok := s.recv()
+			if !ok {
+				// not enough items in s.source, but we need to let successive method to go ASAP.
+				break
+			}
+			if !s.send(source, item) {
+				break
+			}
 			n--
-			if n >= 0 {
-				source <- item
+		}
+		// why we don't just return, and drain to consume all items.
+		// because if returns, the former goroutine will block forever,
+		// which will cause goroutine leak.
+		go drain(s.input())
+	}()
+
+	return s.derive(source)
+}
+
+// Interleave 轮流从当前流和others中各取一个元素写入新流，已结束的流会被跳过，所有流结束后新流结束
+func (s Stream) Interleave(others ...Stream) Stream {
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+
+		streams := make([]Stream, 0, len(others)+1)
+		streams = append(streams, s)
+		for _, each := range others {
+			streams = append(streams, s.adopt(each))
+		}
+		defer func() {
+			for _, each := range streams {
+				go drain(each.input())
 			}
-			if n == 0 {
-				// let successive method go ASAP even we have more items to skip
-				close(source)
-				// why we don't just break the loop, and drain to consume all items.
-				// because if breaks, this former goroutine will block forever,
-				// which will cause goroutine leak.
-				drain(s.source)
+		}()
+
+		for len(streams) > 0 {
+			for i := 0; i < len(streams); {
+				item, ok := streams[i].recv()
+				if !ok {
+					if s.cancelled() {
+						return
+					}
+					streams = append(streams[:i], streams[i+1:]...)
+					continue
+				}
+				if !s.send(source, item) {
+					return
+				}
+				i++
 			}
 		}
-		// not enough items in s.source, but we need to let successive method to go ASAP.
-		if n > 0 {
-			close(source)
+
+		// 合并进来的流出错时同样中止合并后的流
+		for _, each := range others {
+			if err := each.err(); err != nil {
+				s.state.fail(err)
+			}
 		}
 	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
 // Last 返回最后一个元素
 func (s Stream) Last() (item any) {
-	for item = range s.source {
+	for {
+		val, ok := s.recv()
+		if !ok {
+			s.release()
+			return
+		}
+		item = val
 	}
-	return
 }
 
-// Map 对象转换
+// Map 对象转换，与相邻且并发设置相同的Filter、Map、MapErr合并为一个阶段，在流被读取时才启动
 func (s Stream) Map(fn MapFunc, opts ...Option) Stream {
+	if option := s.options(opts...); option.fusible() {
+		return s.fuse("Map", option, func(item any) (any, bool) {
+			return fn(item), true
+		})
+	}
+
 	return s.Walk(func(item any, pipe chan<- any) {
 		pipe <- fn(item)
 	}, opts...)
 }
 
+// MapContext 与MapErr相同，但fn接收ctx，ctx在流绑定的ctx取消时取消，设置WithItemTimeout时也会在单次尝试超时后取消，
+// 用于调用下游服务等可以被中断的操作，使超时的调用及时退出
+func (s Stream) MapContext(fn MapContextFunc, opts ...Option) Stream {
+	return s.walkContext(func(ctx context.Context, item any, pipe chan<- any) error {
+		val, err := fn(ctx, item)
+		if err != nil {
+			return err
+		}
+
+		pipe <- val
+		return nil
+	}, s.options(opts...))
+}
+
+// MapErr 与Map相同，但fn可以返回错误，默认第一个错误即中止整条流，
+// 可以通过CollectErrors继续处理后续元素，并在终结操作中返回所有错误
+func (s Stream) MapErr(fn MapErrFunc, opts ...Option) Stream {
+	if option := s.options(opts...); option.fusible() {
+		return s.fuse("MapErr", option, func(item any) (any, bool) {
+			val, err := fn(item)
+			if err == nil {
+				return val, true
+			}
+
+			if option.collectErrors {
+				s.state.collect(err)
+			} else {
+				s.state.fail(err)
+			}
+			return nil, false
+		})
+	}
+
+	return s.WalkErr(func(item any, pipe chan<- any) error {
+		val, err := fn(item)
+		if err != nil {
+			return err
+		}
+
+		pipe <- val
+		return nil
+	}, opts...)
+}
+
 // Max 返回Stream中item的最大值
 func (s Stream) Max(less LessFunc) any {
 	var max any
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		if max == nil || less(max, item) {
 			max = item
 		}
 	}
+	s.release()
 
 	return max
 }
@@ -308,68 +546,120 @@ func (s Stream) Max(less LessFunc) any {
 // Merge 合并item到slice并生成新stream
 func (s Stream) Merge() Stream {
 	var items []any
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		items = append(items, item)
 	}
+	s.release()
 
 	source := make(chan any, 1)
 	source <- items
 	close(source)
 
-	return Range(source)
+	return s.derive(source)
 }
 
 // Min 返回Stream中item的最小值
 func (s Stream) Min(less LessFunc) any {
 	var min any
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		if min == nil || less(item, min) {
 			min = item
 		}
 	}
+	s.release()
 
 	return min
 }
 
 // NoneMatch Stream中的所有元素是否都不满足给定的条件
 func (s Stream) NoneMatch(predicate func(item any) bool) bool {
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		if predicate(item) {
 			// make sure the former goroutine not block, and current func returns fast.
-			go drain(s.source)
+			go drain(s.input())
 			return false
 		}
 	}
+	s.release()
 
 	return true
 }
 
 // Parallel applies the given ParallelFunc to each item concurrently with given number of workers.
-func (s Stream) Parallel(fn ParallelFunc, opts ...Option) {
-	s.Walk(func(item any, pipe chan<- any) {
+// 流被取消或出错时返回对应的错误
+func (s Stream) Parallel(fn ParallelFunc, opts ...Option) error {
+	return s.Walk(func(item any, pipe chan<- any) {
 		fn(item)
 	}, opts...).Done()
 }
 
-// Reduce 汇总
+// Reduce 汇总，流被取消或出错时返回对应的错误
 func (s Stream) Reduce(fn ReduceFunc) (any, error) {
-	return fn(s.source)
+	result, err := fn(s.input())
+	if cerr := s.complete(); cerr != nil {
+		return nil, cerr
+	}
+
+	return result, err
 }
 
 // Reverse reverse可以对流中元素进行反转处理
 func (s Stream) Reverse() Stream {
 	var items []any
 	// 获取流中数据
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		items = append(items, item)
 	}
+	s.release()
 	// 反转算法
 	for i := len(items)/2 - 1; i >= 0; i-- {
 		opp := len(items) - 1 - i
 		items[i], items[opp] = items[opp], items[i]
 	}
 	// 写入流
-	return Just(items...)
+	return s.derive(Just(items...).source)
+}
+
+// Scan 以initial为初始值依次累加流中的元素，并将每次累加后的结果写入新流
+func (s Stream) Scan(initial any, fn ScanFunc) Stream {
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+		defer s.state.recover()
+
+		acc := initial
+		for {
+			item, ok := s.recv()
+			if !ok {
+				return
+			}
+
+			acc = fn(acc, item)
+			if !s.send(source, acc) {
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
 }
 
 // Skip 跳过前n个item，返回新stream
@@ -384,31 +674,46 @@ func (s Stream) Skip(n int64) Stream {
 	source := make(chan any)
 
 	go func() {
-		for item := range s.source {
+		defer close(source)
+		defer s.release()
+		for {
+			item, ok := s.recv()
+			if !ok {
+				return
+			}
 			n--
 			if n >= 0 {
 				continue
-			} else {
-				source <- item
+			}
+			if !s.send(source, item) {
+				return
 			}
 		}
-		close(source)
 	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
-// Sort  对item进行排序
-func (s Stream) Sort(less LessFunc) Stream {
+// Sort  对item进行排序，可以通过WithSpill在元素过多时使用临时文件进行外部排序
+func (s Stream) Sort(less LessFunc, opts ...Option) Stream {
+	if option := s.options(opts...); option.spillLimit > 0 {
+		return s.sortExternal(less, option)
+	}
+
 	var items []any
-	for item := range s.source {
+	for {
+		item, ok := s.recv()
+		if !ok {
+			break
+		}
 		items = append(items, item)
 	}
+	s.release()
 	sort.Slice(items, func(i, j int) bool {
 		return less(items[i], items[j])
 	})
 
-	return Just(items...)
+	return s.derive(Just(items...).source)
 }
 
 // Split 分割对流数据进行分割
@@ -419,21 +724,28 @@ func (s Stream) Split(n int) Stream {
 
 	source := make(chan any)
 	go func() {
+		defer close(source)
+		defer s.release()
 		var chunk []any
-		for item := range s.source {
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
 			chunk = append(chunk, item)
 			if len(chunk) == n {
-				source <- chunk
+				if !s.send(source, chunk) {
+					return
+				}
 				chunk = nil
 			}
 		}
 		if chunk != nil {
-			source <- chunk
+			s.send(source, chunk)
 		}
-		close(source)
 	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
 // Tail 与Head功能类似，取出后n个item组成新stream
@@ -445,84 +757,476 @@ func (s Stream) Tail(n int64) Stream {
 	source := make(chan any)
 
 	go func() {
+		defer close(source)
+		defer s.release()
 		ring := collection.NewRing(int(n))
-		for item := range s.source {
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
 			ring.Add(item)
 		}
 		for _, item := range ring.Take() {
-			source <- item
+			if !s.send(source, item) {
+				return
+			}
 		}
-		close(source)
 	}()
 
-	return Range(source)
+	return s.derive(source)
 }
 
 // Walk Walk函数并发的作用在流中每一个item上，可以通过WithWorkers设置并发数，默认并发数为16，
 // 最小并发数为1，如设置unlimitedWorkers为true则并发数无限制，但并发写入流中的数据由defaultWorkers限制，
 // WalkFunc中用户可以自定义后续写入流中的元素，可以不写入也可以写入多个元素
+// 流被取消后不再派发新的item，已在执行的WalkFunc写入pipe的数据会被下游丢弃
+// WalkFunc中的panic会被转换为PanicError并中止整条流
+// 默认按处理完成的顺序输出，可以通过Ordered按输入顺序输出
+// 可以通过WithAdaptiveWorkers根据处理耗时自动调整并发数
 func (s Stream) Walk(fn WalkFunc, opts ...Option) Stream {
-	option := buildOptions(opts...)
+	option := s.options(opts...)
+	if option.guarded() {
+		return s.walkErr(func(item any, pipe chan<- any) error {
+			fn(item, pipe)
+			return nil
+		}, option)
+	}
+
+	return s.walk(fn, option)
+}
+
+func (s Stream) walk(fn WalkFunc, option *rxOptions) Stream {
+	st := s.state.newStage()
+	st.op = walkOp(option)
+	workers := func() int {
+		if option.unlimitedWorkers {
+			return 0
+		}
+		return option.workers
+	}
+
+	if option.ordered {
+		return s.walkOrdered(s.observed(fn, st, workers, option.clock), option, st)
+	}
 	if option.unlimitedWorkers {
-		return s.walkUnlimited(fn, option)
+		return s.walkUnlimited(s.observed(fn, st, workers, option.clock), option, st)
+	}
+	if option.adaptiveMax > 0 {
+		limiter := newAimdLimiter(option.adaptiveMin, option.adaptiveMax)
+		return s.walkAdaptive(s.observed(fn, st, limiter.current, option.clock), option, limiter, st)
 	}
 
-	return s.walkLimited(fn, option)
+	return s.walkLimited(s.observed(fn, st, workers, option.clock), option, st)
 }
 
-func (s Stream) walkLimited(fn WalkFunc, option *rxOptions) Stream {
+// walkOp 返回Walk阶段在Explain中显示的操作名
+func walkOp(option *rxOptions) string {
+	if option.unlimitedWorkers {
+		return "Walk(workers=unlimited)"
+	}
+
+	return fmt.Sprintf("Walk(workers=%d)", option.workers)
+}
+
+func (s Stream) walkLimited(fn WalkFunc, option *rxOptions, st *stage) Stream {
 	pipe := make(chan any, option.workers)
 
 	go func() {
 		var wg sync.WaitGroup
 		pool := make(chan lang.PlaceholderType, option.workers)
 
-		for item := range s.source {
+	dispatch:
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+
+			select {
+			case pool <- lang.Placeholder:
+			case <-s.ctx.Done():
+				break dispatch
+			case <-s.state.done:
+				break dispatch
+			}
+
 			// important, used in another goroutine
 			val := item
-			pool <- lang.Placeholder
 			wg.Add(1)
 
 			// better to safely run caller defined method
-			thread.SafeGoroutine(func() {
+			go func() {
 				defer func() {
 					wg.Done()
 					<-pool
 				}()
+				defer s.state.recover()
 
 				fn(val, pipe)
-			})
+			}()
 		}
 
+		// 流被取消时上游可能仍在写入，清空以免上游阻塞
+		s.release()
+		// 流被取消后下游不再读取，清空pipe以免worker阻塞在写入上
+		stop := s.drainCancelled(pipe)
 		wg.Wait()
 		close(pipe)
+		stop()
 	}()
 
-	return Range(pipe)
+	return s.deriveStage(pipe, st)
 }
 
-func (s Stream) walkUnlimited(fn WalkFunc, option *rxOptions) Stream {
+// walkSerial 在单个goroutine中依次对每个item执行fn，不为每个item单独创建goroutine
+func (s Stream) walkSerial(fn WalkFunc, st *stage) Stream {
+	pipe := make(chan any, minWorkers)
+
+	go func() {
+		defer close(pipe)
+		defer s.release()
+		defer s.state.recover()
+
+		for {
+			item, ok := s.recv()
+			if !ok {
+				return
+			}
+			fn(item, pipe)
+		}
+	}()
+
+	return s.deriveStage(pipe, st)
+}
+
+// walkOrdered 与walkLimited相同并发的执行fn，但每个item的输出按输入顺序写入下游
+// 每个item的输出写入各自的channel，再由单独的goroutine按顺序转发，
+// 已派发但未转发的item最多为workers个，队首item较慢时后续派发会被阻塞，从而限制重排序缓冲的大小
+func (s Stream) walkOrdered(fn WalkFunc, option *rxOptions, st *stage) Stream {
+	pipe := make(chan any, option.workers)
+	// 按输入顺序排列的每个item的输出channel
+	order := make(chan chan any, option.workers)
+
+	go func() {
+		defer close(order)
+		pool := make(chan lang.PlaceholderType, option.workers)
+
+	dispatch:
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+
+			if !option.unlimitedWorkers {
+				select {
+				case pool <- lang.Placeholder:
+				case <-s.ctx.Done():
+					break dispatch
+				case <-s.state.done:
+					break dispatch
+				}
+			}
+
+			// 至少缓冲一个结果，使Map类操作的worker无需等待转发即可退出
+			out := make(chan any, 1)
+			select {
+			case order <- out:
+			case <-s.ctx.Done():
+				break dispatch
+			case <-s.state.done:
+				break dispatch
+			}
+
+			// important, used in another goroutine
+			val := item
+			go func() {
+				defer func() {
+					close(out)
+					if !option.unlimitedWorkers {
+						<-pool
+					}
+				}()
+				defer s.state.recover()
+
+				fn(val, out)
+			}()
+		}
+
+		s.release()
+	}()
+
+	go func() {
+		defer close(pipe)
+		for out := range order {
+			for item := range out {
+				if !s.send(pipe, item) {
+					// 流被取消，清空剩余输出以免worker阻塞
+					go drain(out)
+					go drainOrder(order)
+					return
+				}
+			}
+		}
+	}()
+
+	return s.deriveStage(pipe, st)
+}
+
+// WalkContext 与WalkErr相同，但fn接收ctx，ctx在流绑定的ctx取消时取消，设置WithItemTimeout时也会在单次尝试超时后取消
+func (s Stream) WalkContext(fn WalkContextFunc, opts ...Option) Stream {
+	return s.walkContext(fn, s.options(opts...))
+}
+
+func (s Stream) walkContext(fn WalkContextFunc, option *rxOptions) Stream {
+	run := func(item any, pipe chan<- any) error {
+		return fn(s.ctx, item, pipe)
+	}
+	if option.guarded() {
+		run = s.retryable(fn, option)
+	}
+
+	return s.walk(func(item any, pipe chan<- any) {
+		if err := run(item, pipe); err != nil {
+			if option.collectErrors {
+				s.state.collect(err)
+			} else {
+				s.state.fail(err)
+			}
+		}
+	}, option)
+}
+
+// WalkErr 与Walk相同，但fn可以返回错误，默认第一个错误即中止整条流，
+// 可以通过CollectErrors继续处理后续元素，并在终结操作中返回所有错误
+func (s Stream) WalkErr(fn WalkErrFunc, opts ...Option) Stream {
+	return s.walkErr(fn, s.options(opts...))
+}
+
+func (s Stream) walkErr(fn WalkErrFunc, option *rxOptions) Stream {
+	return s.walkContext(func(ctx context.Context, item any, pipe chan<- any) error {
+		return fn(item, pipe)
+	}, option)
+}
+
+func (s Stream) walkUnlimited(fn WalkFunc, option *rxOptions, st *stage) Stream {
 	pipe := make(chan any, option.workers)
 
 	go func() {
 		var wg sync.WaitGroup
 
-		for item := range s.source {
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+
 			// important, used in another goroutine
 			val := item
 			wg.Add(1)
 			// better to safely run caller defined method
-			thread.SafeGoroutine(func() {
+			go func() {
 				defer wg.Done()
+				defer s.state.recover()
 				fn(val, pipe)
-			})
+			}()
 		}
 
+		// 流被取消时上游可能仍在写入，清空以免上游阻塞
+		s.release()
+		// 流被取消后下游不再读取，清空pipe以免worker阻塞在写入上
+		stop := s.drainCancelled(pipe)
 		wg.Wait()
 		close(pipe)
+		stop()
+	}()
+
+	return s.deriveStage(pipe, st)
+}
+
+// Zip 将当前流和other中相同位置的元素合并为[]any{a, b}写入新流，任意一个流结束后新流结束
+func (s Stream) Zip(other Stream) Stream {
+	return s.ZipWith(other, func(a, b any) any {
+		return []any{a, b}
+	})
+}
+
+// ZipWith 将当前流和other中相同位置的元素通过fn合并后写入新流，任意一个流结束后新流结束
+func (s Stream) ZipWith(other Stream, fn ZipFunc) Stream {
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		// 另一个流可能还有剩余元素，清空以免上游阻塞
+		defer func() {
+			go drain(s.input())
+			go drain(other.input())
+		}()
+		defer s.state.recover()
+
+		right := s.adopt(other)
+		for {
+			a, ok := s.recv()
+			if !ok {
+				return
+			}
+			b, ok := right.recv()
+			if !ok {
+				// 合并进来的流出错时同样中止合并后的流
+				if err := other.err(); err != nil {
+					s.state.fail(err)
+				}
+				return
+			}
+
+			if !s.send(source, fn(a, b)) {
+				return
+			}
+		}
 	}()
 
-	return Range(pipe)
+	return s.derive(source)
+}
+
+// cancelled 流是否已被取消或因出错而中止
+func (s Stream) cancelled() bool {
+	select {
+	case <-s.state.done:
+		return true
+	default:
+		return s.ctx.Err() != nil
+	}
+}
+
+// complete 结束对流的消费，返回流被取消或中止的原因
+func (s Stream) complete() error {
+	s.release()
+	return s.err()
+}
+
+// derive 使用新的source构建下游流，并沿用当前流的ctx和state
+func (s Stream) derive(source <-chan any) Stream {
+	st := s.state.newStage()
+	st.op = callerOp(1)
+
+	return s.deriveStage(source, st)
+}
+
+// deriveStage 与derive相同，但新的流由阶段st产生
+func (s Stream) deriveStage(source <-chan any, st *stage) Stream {
+	s.stage.consumer.CompareAndSwap(nil, st)
+	st.parent = s.stage
+
+	return Stream{
+		source: source,
+		ctx:    s.ctx,
+		state:  s.state,
+		stage:  st,
+	}
+}
+
+// err 返回流中记录的错误，没有错误时返回ctx.Err()
+func (s Stream) err() error {
+	if err := s.state.err(); err != nil {
+		return err
+	}
+
+	return s.ctx.Err()
+}
+
+// pipeTo 将流中元素依次写入pipe，流被取消时停止写入
+func (s Stream) pipeTo(pipe chan<- any) {
+	defer s.release()
+	for {
+		item, ok := s.recv()
+		if !ok || !s.send(pipe, item) {
+			return
+		}
+	}
+}
+
+// drainCancelled 在流被取消后清空pipe，使阻塞在写入pipe上的worker退出，
+// pipe关闭后需要调用返回的stop结束等待
+func (s Stream) drainCancelled(pipe <-chan any) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-s.ctx.Done():
+		case <-s.state.done:
+		case <-done:
+			return
+		}
+		drain(pipe)
+	}()
+
+	return func() {
+		close(done)
+	}
+}
+
+// release 流被取消时，若source的写入方不感知取消(From、Range)，在后台清空source使其退出，
+// 其他写入方会自行监听取消并退出，无需清空
+func (s Stream) release() {
+	if s.drainSource && s.cancelled() {
+		go drain(s.input())
+	}
+}
+
+// sourceContext 返回在s.ctx取消或流被中止时取消的ctx，用于通知源停止产生元素，
+// 使用完毕后需要调用返回的cancel
+func (s Stream) sourceContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.state.done:
+			cancel()
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// recv 读取流中下一个元素，流读完或被取消时返回false
+func (s Stream) recv() (any, bool) {
+	select {
+	case item, ok := <-s.input():
+		if ok {
+			s.observeRecv()
+		}
+		return item, ok
+	case <-s.ctx.Done():
+		return nil, false
+	case <-s.state.done:
+		return nil, false
+	}
+}
+
+// send 将item写入pipe，流被取消时放弃写入并返回false
+func (s Stream) send(pipe chan<- any, item any) bool {
+	select {
+	case pipe <- item:
+		return true
+	case <-s.ctx.Done():
+		return false
+	case <-s.state.done:
+		return false
+	}
+}
+
+// CollectErrors 使MapErr和WalkErr出错时继续处理后续元素，终结操作返回收集到的所有错误
+func CollectErrors() Option {
+	return func(opts *rxOptions) {
+		opts.collectErrors = true
+	}
+}
+
+// Ordered 使Walk及基于Walk的Map、Filter等操作按输入顺序输出结果，同时保持并发处理
+func Ordered() Option {
+	return func(opts *rxOptions) {
+		opts.ordered = true
+	}
 }
 
 // UnlimitedWorkers lets the caller use as many workers as the tasks.
@@ -532,6 +1236,13 @@ func UnlimitedWorkers() Option {
 	}
 }
 
+// WithClock 指定基于时间的操作所使用的时钟，默认使用系统时钟
+func WithClock(clock Clock) Option {
+	return func(opts *rxOptions) {
+		opts.clock = clock
+	}
+}
+
 // WithWorkers lets the caller customize the concurrent workers.
 func WithWorkers(workers int) Option {
 	return func(opts *rxOptions) {
@@ -559,9 +1270,31 @@ func drain(channel <-chan any) {
 	}
 }
 
+// drainOrder 清空order中每个item的输出channel
+func drainOrder(order <-chan chan any) {
+	for out := range order {
+		drain(out)
+	}
+}
+
+// newStream 基于ctx和source构建一条新的流
+func newStream(ctx context.Context, source <-chan any) Stream {
+	state := newStreamState()
+	st := state.newStage()
+	st.op = callerOp(1)
+
+	return Stream{
+		source: source,
+		ctx:    ctx,
+		state:  state,
+		stage:  st,
+	}
+}
+
 // newOptions 返回一个默认的rxOptions指针
 func newOptions() *rxOptions {
 	return &rxOptions{
 		workers: defaultWorkers,
+		clock:   realClock{},
 	}
 }
@@ -1,11 +1,15 @@
 package fx
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -85,6 +89,303 @@ func TestFrom(t *testing.T) {
 	t.Log(s)
 }
 
+func TestFromContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var count int
+	err := FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		for i := 0; ; i++ {
+			select {
+			case source <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}).Map(func(item any) any {
+		return item.(int) * 2
+	}).ForEach(func(item any) {
+		count++
+		if count == 10 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+	t.Log(count)
+}
+
+func TestFromContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	start := time.Now()
+	// Walk中的阻塞写入不会卡住终结操作
+	err := FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		for i := 0; i < 100; i++ {
+			select {
+			case source <- i:
+			case <-ctx.Done():
+				return
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}).Walk(func(item any, pipe chan<- any) {
+		pipe <- item
+		pipe <- item
+	}).Done()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Fatalf("stream should stop soon after timeout, elapsed %v", elapsed)
+	}
+}
+
+func TestMapErr(t *testing.T) {
+	errBad := errors.New("bad record")
+	var count int
+	err := From(func(source chan<- any) {
+		source <- "1"
+		source <- "2"
+		source <- "x"
+		for i := 0; i < 100; i++ {
+			source <- strconv.Itoa(i)
+		}
+	}).MapErr(func(item any) (any, error) {
+		val, err := strconv.Atoi(item.(string))
+		if err != nil {
+			return nil, errBad
+		}
+		return val, nil
+	}, WithWorkers(1)).ForEach(func(item any) {
+		count++
+	})
+	if !errors.Is(err, errBad) {
+		t.Fatalf("expect errBad, got %v", err)
+	}
+	if count > 10 {
+		t.Fatalf("stream should stop after error, got %d items", count)
+	}
+}
+
+func TestWalkErrCollectErrors(t *testing.T) {
+	count, err := Just(1, 2, 3, 4, 5, 6).WalkErr(func(item any, pipe chan<- any) error {
+		if item.(int)%2 == 0 {
+			return fmt.Errorf("even number %d", item)
+		}
+		pipe <- item
+		return nil
+	}, CollectErrors()).Count()
+	if count != 3 {
+		t.Fatalf("expect 3 items, got %d", count)
+	}
+	var be *BatchError
+	if !errors.As(err, &be) || len(be.Errors()) != 3 {
+		t.Fatalf("expect BatchError with 3 errors, got %v", err)
+	}
+}
+
+func TestWalkPanic(t *testing.T) {
+	err := Just(1, 2, 3).Map(func(item any) any {
+		if item.(int) == 2 {
+			panic("boom")
+		}
+		return item
+	}).Filter(func(item any) bool {
+		return true
+	}).Done()
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || !strings.Contains(pe.Error(), "goroutine") {
+		t.Fatalf("expect PanicError with stack, got %v", err)
+	}
+}
+
+func TestFromPanic(t *testing.T) {
+	_, err := From(func(source chan<- any) {
+		source <- 1
+		panic("generator broken")
+	}).Reduce(func(pipe <-chan any) (any, error) {
+		drain(pipe)
+		return nil, nil
+	})
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expect PanicError, got %v", err)
+	}
+}
+
+func TestMapOrdered(t *testing.T) {
+	var items []any
+	err := From(func(source chan<- any) {
+		for i := 0; i < 100; i++ {
+			source <- i
+		}
+	}).Map(func(item any) any {
+		// 让后面的元素先处理完成
+		time.Sleep(time.Millisecond * time.Duration(10-item.(int)%10))
+		return item.(int) * 2
+	}, WithWorkers(8), Ordered()).ForEach(func(item any) {
+		items = append(items, item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 100 {
+		t.Fatalf("expect 100 items, got %d", len(items))
+	}
+	for i, item := range items {
+		if item.(int) != i*2 {
+			t.Fatalf("expect %d at %d, got %v", i*2, i, item)
+		}
+	}
+}
+
+func TestWalkOrderedCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var count int
+	err := FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		for i := 0; i < 1000; i++ {
+			select {
+			case source <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}).Walk(func(item any, pipe chan<- any) {
+		pipe <- item
+		pipe <- item
+	}, UnlimitedWorkers(), Ordered()).ForEach(func(item any) {
+		count++
+		if count == 10 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+}
+
+func TestCancelReleasesGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	infinite := func(ctx context.Context, source chan<- any) {
+		for i := 0; ; i++ {
+			select {
+			case source <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	items := make([]any, 200)
+	for i := range items {
+		items[i] = i
+	}
+
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		var count int
+		FromContext(ctx, infinite).Map(func(item any) any {
+			return item
+		}, WithWorkers(4)).ForEach(func(item any) {
+			if count++; count == 3 {
+				cancel()
+			}
+		})
+
+		ctx, cancel = context.WithCancel(context.Background())
+		count = 0
+		FromContext(ctx, infinite).FlatMap(func(item any) []any {
+			return []any{item, item}
+		}, UnlimitedWorkers()).ForEach(func(item any) {
+			if count++; count == 3 {
+				cancel()
+			}
+		})
+
+		Just(1).Concat(Just(items...).Map(func(item any) any {
+			return item
+		})).Map(func(item any) any {
+			panic("boom")
+		}).Done()
+	}
+
+	deadline := time.Now().Add(time.Second * 2)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expect goroutines to exit, before %d, after %d", before, after)
+	}
+}
+
+func TestZip(t *testing.T) {
+	var items []any
+	err := Just(1, 2, 3).ZipWith(Just("a", "b"), func(a, b any) any {
+		return strconv.Itoa(a.(int)) + b.(string)
+	}).ForEach(func(item any) {
+		items = append(items, item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0] != "1a" || items[1] != "2b" {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	pair := Just(1).Zip(Just(2, 3)).First().([]any)
+	if pair[0] != 1 || pair[1] != 2 {
+		t.Fatalf("unexpected pair %v", pair)
+	}
+}
+
+func TestFlatMap(t *testing.T) {
+	count, err := Just("a b", "c d e", "").FlatMap(func(item any) []any {
+		var words []any
+		for _, word := range strings.Fields(item.(string)) {
+			words = append(words, word)
+		}
+		return words
+	}, WithWorkers(2)).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Fatalf("expect 5, got %d", count)
+	}
+}
+
+func TestScan(t *testing.T) {
+	var items []any
+	err := Just(1, 2, 3, 4).Scan(0, func(acc, item any) any {
+		return acc.(int) + item.(int)
+	}).ForEach(func(item any) {
+		items = append(items, item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(items) != "[1 3 6 10]" {
+		t.Fatalf("unexpected items %v", items)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	var items []any
+	err := Just(1, 2, 3).Interleave(Just("a"), Just("x", "y")).ForEach(func(item any) {
+		items = append(items, item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(items) != "[1 a x 2 y 3]" {
+		t.Fatalf("unexpected items %v", items)
+	}
+}
+
 func TestJust(t *testing.T) {
 	Just(1, 2, 3, 4, 5, 6, 7, 8, 9, 10).
 		Split(4).
@@ -262,3 +563,70 @@ func TestName(t *testing.T) {
 func (time Option) name() {
 
 }
+
+func TestFromContextStopsGenerator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var produced int64
+	stopped := make(chan struct{})
+	var count int
+	err := FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case source <- i:
+				atomic.AddInt64(&produced, 1)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}).Walk(func(item any, pipe chan<- any) {
+		pipe <- item
+	}).ForEach(func(item any) {
+		count++
+		if count == 100 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+
+	// 取消后生成器立即退出，而不是被后台清空source驱动着继续产生元素
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("generator still running after cancel")
+	}
+	if n := atomic.LoadInt64(&produced); n > 1000 {
+		t.Fatalf("expect generator to stop soon after cancel, produced %d", n)
+	}
+}
+
+func TestFromContextStopsOnFailure(t *testing.T) {
+	stopped := make(chan struct{})
+	errBad := errors.New("bad")
+	err := FromContext(context.Background(), func(ctx context.Context, source chan<- any) {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case source <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}).MapErr(func(item any) (any, error) {
+		return nil, errBad
+	}).Done()
+	if !errors.Is(err, errBad) {
+		t.Fatalf("expect errBad, got %v", err)
+	}
+
+	// 流被中止时生成器的ctx同样被取消
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("generator still running after failure")
+	}
+}
@@ -0,0 +1,172 @@
+package fx
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// boundedHeap 是最多保存k个元素的小顶堆，堆顶为已保存元素中最小的一个，用于保留最大的k个元素
+type boundedHeap struct {
+	items []any
+	less  LessFunc
+	k     int
+}
+
+// newBoundedHeap 返回一个boundedHeap
+func newBoundedHeap(k int, less LessFunc) *boundedHeap {
+	return &boundedHeap{
+		less: less,
+		k:    k,
+	}
+}
+
+func (h *boundedHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *boundedHeap) Less(i, j int) bool {
+	return h.less(h.items[i], h.items[j])
+}
+
+func (h *boundedHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *boundedHeap) Push(x any) {
+	h.items = append(h.items, x)
+}
+
+func (h *boundedHeap) Pop() any {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	return item
+}
+
+// offer 尝试加入item，已满时item大于堆顶才会替换堆顶
+func (h *boundedHeap) offer(item any) {
+	if len(h.items) < h.k {
+		heap.Push(h, item)
+	} else if h.less(h.items[0], item) {
+		h.items[0] = item
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted 取出所有元素并按从大到小排列
+func (h *boundedHeap) sorted() []any {
+	items := make([]any, len(h.items))
+	for i := len(items) - 1; i >= 0; i-- {
+		items[i] = heap.Pop(h)
+	}
+
+	return items
+}
+
+// BottomK 返回按less最小的k个元素组成的新流，按从小到大输出，只使用大小为k的堆
+func (s Stream) BottomK(k int, less LessFunc) Stream {
+	return s.TopK(k, reverseLess(less))
+}
+
+// ParallelBottomK 与BottomK相同，但由多个worker并发的维护各自的堆，最后合并，可以通过WithWorkers设置并发数
+func (s Stream) ParallelBottomK(k int, less LessFunc, opts ...Option) Stream {
+	return s.ParallelTopK(k, reverseLess(less), opts...)
+}
+
+// ParallelTopK 与TopK相同，但由多个worker并发的维护各自的堆，最后合并，可以通过WithWorkers设置并发数，
+// 适合less开销较大的场景
+func (s Stream) ParallelTopK(k int, less LessFunc, opts ...Option) Stream {
+	if k < 1 {
+		panic("k should be greater than 0")
+	}
+
+	option := s.options(opts...)
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+
+		heaps := make([]*boundedHeap, option.workers)
+		var wg sync.WaitGroup
+		for i := range heaps {
+			h := newBoundedHeap(k, less)
+			heaps[i] = h
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer s.state.recover()
+
+				for {
+					item, ok := s.recv()
+					if !ok {
+						return
+					}
+					h.offer(item)
+				}
+			}()
+		}
+		wg.Wait()
+		if s.cancelled() {
+			return
+		}
+
+		merged := newBoundedHeap(k, less)
+		for _, h := range heaps {
+			for _, item := range h.items {
+				merged.offer(item)
+			}
+		}
+		for _, item := range merged.sorted() {
+			if !s.send(source, item) {
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// TopK 返回按less最大的k个元素组成的新流，按从大到小输出，
+// 与Sort不同，只使用大小为k的堆，不需要保存所有元素
+func (s Stream) TopK(k int, less LessFunc) Stream {
+	if k < 1 {
+		panic("k should be greater than 0")
+	}
+
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+		defer s.state.recover()
+
+		h := newBoundedHeap(k, less)
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+			h.offer(item)
+		}
+		if s.cancelled() {
+			return
+		}
+
+		for _, item := range h.sorted() {
+			if !s.send(source, item) {
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// reverseLess 返回与less顺序相反的LessFunc
+func reverseLess(less LessFunc) LessFunc {
+	return func(a, b any) bool {
+		return less(b, a)
+	}
+}
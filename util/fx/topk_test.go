@@ -0,0 +1,104 @@
+package fx
+
+import (
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTopK(t *testing.T) {
+	less := func(a, b any) bool {
+		return a.(int) < b.(int)
+	}
+	items := make([]any, 1000)
+	for i, v := range rand.Perm(len(items)) {
+		items[i] = v
+	}
+
+	tests := []struct {
+		name   string
+		stream func() Stream
+		expect []any
+	}{
+		{
+			name: "top",
+			stream: func() Stream {
+				return Just(items...).TopK(3, less)
+			},
+			expect: []any{999, 998, 997},
+		},
+		{
+			name: "bottom",
+			stream: func() Stream {
+				return Just(items...).BottomK(3, less)
+			},
+			expect: []any{0, 1, 2},
+		},
+		{
+			name: "parallel top",
+			stream: func() Stream {
+				return Just(items...).ParallelTopK(3, less, WithWorkers(4))
+			},
+			expect: []any{999, 998, 997},
+		},
+		{
+			name: "parallel bottom",
+			stream: func() Stream {
+				return Just(items...).ParallelBottomK(3, less)
+			},
+			expect: []any{0, 1, 2},
+		},
+		{
+			name: "less than k",
+			stream: func() Stream {
+				return Just(2, 3, 1).TopK(5, less)
+			},
+			expect: []any{3, 2, 1},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var result []any
+			if err := test.stream().ForEach(func(item any) {
+				result = append(result, item)
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expect) {
+				t.Fatalf("expect %v, got %v", test.expect, result)
+			}
+		})
+	}
+}
+
+func TestParallelTopKFusedSerial(t *testing.T) {
+	var active, overlapped int32
+	items := make([]any, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	result, err := Just(items...).Map(func(item any) any {
+		if atomic.AddInt32(&active, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(time.Microsecond)
+		atomic.AddInt32(&active, -1)
+		return item
+	}, WithWorkers(1)).ParallelTopK(3, func(a, b any) bool {
+		return a.(int) < b.(int)
+	}, WithWorkers(4)).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Fatal("fused Map should not run concurrently")
+	}
+	if !reflect.DeepEqual(result, []any{99, 98, 97}) {
+		t.Fatalf("expect [99 98 97], got %v", result)
+	}
+}
@@ -0,0 +1,65 @@
+package typed
+
+// Joining 将流中元素的字符串表示以sep连接起来，见fx.Stream.Joining
+func (s Stream[T]) Joining(sep string) (string, error) {
+	return s.stream.Joining(sep)
+}
+
+// Partitioned 按predicate将流中元素分为满足和不满足条件的两部分
+func (s Stream[T]) Partitioned(predicate func(item T) bool) (matched, unmatched []T, err error) {
+	err = s.ForEach(func(item T) {
+		if predicate(item) {
+			matched = append(matched, item)
+		} else {
+			unmatched = append(unmatched, item)
+		}
+	})
+
+	return matched, unmatched, err
+}
+
+// ToChannel 将流中元素依次写入pipe，直到流结束、被取消或出错，流绑定的ctx取消后不再阻塞在pipe上，pipe由调用方负责关闭
+func (s Stream[T]) ToChannel(pipe chan<- T) error {
+	return s.ForEach(func(item T) {
+		select {
+		case pipe <- item:
+		case <-s.ctx.Done():
+		}
+	})
+}
+
+// ToSlice 将流中所有元素按顺序放入slice
+func (s Stream[T]) ToSlice() ([]T, error) {
+	var items []T
+	err := s.ForEach(func(item T) {
+		items = append(items, item)
+	})
+
+	return items, err
+}
+
+// GroupingBy 按fn返回的key对流中元素分组，返回每个key对应的元素
+func GroupingBy[T any, K comparable](s Stream[T], fn func(item T) K) (map[K][]T, error) {
+	groups := make(map[K][]T)
+	err := s.ForEach(func(item T) {
+		key := fn(item)
+		groups[key] = append(groups[key], item)
+	})
+
+	return groups, err
+}
+
+// ToMap 将流中元素转换为map，key相同时通过mergeFn合并，mergeFn为nil时后出现的值覆盖先出现的值
+func ToMap[T any, K comparable, V any](s Stream[T], keyFn func(item T) K, valueFn func(item T) V,
+	mergeFn func(old, new V) V) (map[K]V, error) {
+	result := make(map[K]V)
+	err := s.ForEach(func(item T) {
+		key, val := keyFn(item), valueFn(item)
+		if old, ok := result[key]; ok && mergeFn != nil {
+			val = mergeFn(old, val)
+		}
+		result[key] = val
+	})
+
+	return result, err
+}
@@ -0,0 +1,105 @@
+package typed
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToSliceToMap(t *testing.T) {
+	items, err := Just(3, 1, 2).ToSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []int{3, 1, 2}) {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	lengths, err := ToMap(Just("go", "gin", "php"), func(item string) byte {
+		return item[0]
+	}, func(item string) int {
+		return len(item)
+	}, func(old, new int) int {
+		return old + new
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lengths, map[byte]int{'g': 5, 'p': 3}) {
+		t.Fatalf("unexpected map %v", lengths)
+	}
+}
+
+func TestGroupingByPartitioned(t *testing.T) {
+	groups, err := GroupingBy(Just(1, 2, 3, 4, 5), func(item int) bool {
+		return item%2 == 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(groups, map[bool][]int{true: {2, 4}, false: {1, 3, 5}}) {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	small, large, err := Just(1, 5, 2, 8).Partitioned(func(item int) bool {
+		return item < 3
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(small, []int{1, 2}) || !reflect.DeepEqual(large, []int{5, 8}) {
+		t.Fatalf("unexpected partitions %v %v", small, large)
+	}
+
+	joined, err := Just("a", "b", "c").Joining("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined != "a-b-c" {
+		t.Fatalf("unexpected string %s", joined)
+	}
+}
+
+func TestToChannel(t *testing.T) {
+	pipe := make(chan int, 3)
+	if err := Just(1, 2, 3).ToChannel(pipe); err != nil {
+		t.Fatal(err)
+	}
+	close(pipe)
+
+	var sum int
+	for item := range pipe {
+		sum += item
+	}
+	if sum != 6 {
+		t.Fatalf("expect 6, got %d", sum)
+	}
+}
+
+func TestToChannelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := FromContext(ctx, func(ctx context.Context, source chan<- int) {
+		select {
+		case source <- 1:
+		case <-ctx.Done():
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		// 没有人读取pipe，取消后ToChannel应当返回
+		done <- stream.ToChannel(make(chan int))
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expect context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ToChannel should return after ctx cancelled")
+	}
+}
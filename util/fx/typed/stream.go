@@ -0,0 +1,188 @@
+package typed
+
+import (
+	"context"
+	"just4play/util/fx"
+)
+
+// Stream 是fx.Stream的泛型封装，元素类型在编译期确定，调用方无需再做类型断言
+// 所有操作都委托给底层的fx.Stream，因此共享相同的并发行为和fx.Option
+type Stream[T any] struct {
+	stream fx.Stream
+	// ctx 是流绑定的ctx，ToChannel等阻塞写入的操作在其取消后返回
+	ctx context.Context
+}
+
+// From 通过generate构建流，generate向source写入元素
+func From[T any](generate func(source chan<- T)) Stream[T] {
+	return wrap[T](context.Background(), fx.From(func(source chan<- any) {
+		forward(context.Background(), generate, source)
+	}))
+}
+
+// FromContext 与From相同，但流绑定了ctx，generate应在写入时监听ctx.Done()，见fx.FromContext
+func FromContext[T any](ctx context.Context, generate func(ctx context.Context, source chan<- T)) Stream[T] {
+	return wrap[T](ctx, fx.FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		forward(ctx, func(pipe chan<- T) {
+			generate(ctx, pipe)
+		}, source)
+	}))
+}
+
+// Just 批量传入元素构建流
+func Just[T any](items ...T) Stream[T] {
+	source := make(chan any, len(items))
+	for _, item := range items {
+		source <- item
+	}
+	close(source)
+
+	return wrap[T](context.Background(), fx.Range(source))
+}
+
+// Range 将给定的channel转换为流
+func Range[T any](source <-chan T) Stream[T] {
+	return From(func(pipe chan<- T) {
+		for item := range source {
+			pipe <- item
+		}
+	})
+}
+
+// Count 统计流中元素个数
+func (s Stream[T]) Count() (int, error) {
+	return s.stream.Count()
+}
+
+// Distinct 按fn返回的key对流中元素去重，相同key只保留第一个，可以通过fx.WithLRUDedup等指定去重方式
+func (s Stream[T]) Distinct(fn func(item T) any, opts ...fx.Option) Stream[T] {
+	return wrap[T](s.ctx, s.stream.Distinct(func(item any) any {
+		return fn(cast[T](item))
+	}, opts...))
+}
+
+// Done 等待所有上游操作完成，流被取消或出错时返回对应的错误
+func (s Stream[T]) Done() error {
+	return s.stream.Done()
+}
+
+// Explain 返回产生当前流的物理执行计划，见fx.Stream.Explain
+func (s Stream[T]) Explain() string {
+	return s.stream.Explain()
+}
+
+// Filter 过滤不满足条件的item
+func (s Stream[T]) Filter(fn func(item T) bool, opts ...fx.Option) Stream[T] {
+	return wrap[T](s.ctx, s.stream.Filter(func(item any) bool {
+		return fn(cast[T](item))
+	}, opts...))
+}
+
+// ForEach 遍历流中所有元素，流被取消或出错时返回对应的错误
+func (s Stream[T]) ForEach(fn func(item T)) error {
+	return s.stream.ForEach(func(item any) {
+		fn(cast[T](item))
+	})
+}
+
+// Head 取出前n个item，返回新stream
+func (s Stream[T]) Head(n int64) Stream[T] {
+	return wrap[T](s.ctx, s.stream.Head(n))
+}
+
+// Named 为产生当前流的阶段命名，见fx.Stream.Named
+func (s Stream[T]) Named(name string) Stream[T] {
+	return wrap[T](s.ctx, s.stream.Named(name))
+}
+
+// Reverse 对流中元素进行反转
+func (s Stream[T]) Reverse() Stream[T] {
+	return wrap[T](s.ctx, s.stream.Reverse())
+}
+
+// Sort 对item进行排序，可以通过fx.WithSpill在元素过多时使用临时文件进行外部排序
+func (s Stream[T]) Sort(less func(a, b T) bool, opts ...fx.Option) Stream[T] {
+	return wrap[T](s.ctx, s.stream.Sort(func(a, b any) bool {
+		return less(cast[T](a), cast[T](b))
+	}, opts...))
+}
+
+// Tail 取出后n个item组成新stream
+func (s Stream[T]) Tail(n int64) Stream[T] {
+	return wrap[T](s.ctx, s.stream.Tail(n))
+}
+
+// Group 按fn返回的key对流数据进行分组，每组以[]T写入新流
+func Group[T any, K comparable](s Stream[T], fn func(item T) K) Stream[[]T] {
+	groups := s.stream.Group(func(item any) any {
+		return fn(cast[T](item))
+	})
+
+	return Map(wrap[[]any](s.ctx, groups), func(group []any) []T {
+		items := make([]T, len(group))
+		for i, item := range group {
+			items[i] = cast[T](item)
+		}
+		return items
+	})
+}
+
+// Map 将流中的每个T转换为U，可以通过fx.WithWorkers设置并发数
+func Map[T, U any](s Stream[T], fn func(item T) U, opts ...fx.Option) Stream[U] {
+	return wrap[U](s.ctx, s.stream.Map(func(item any) any {
+		return fn(cast[T](item))
+	}, opts...))
+}
+
+// Reduce 以initial为初始值，依次将流中元素累加到结果上
+func Reduce[T, R any](s Stream[T], initial R, fn func(acc R, item T) R) (R, error) {
+	result, err := s.stream.Reduce(func(pipe <-chan any) (any, error) {
+		acc := initial
+		for item := range pipe {
+			acc = fn(acc, cast[T](item))
+		}
+		return acc, nil
+	})
+	if err != nil {
+		return initial, err
+	}
+
+	return cast[R](result), nil
+}
+
+// cast 将item转换为T，item为nil时返回T的零值
+func cast[T any](item any) T {
+	val, _ := item.(T)
+	return val
+}
+
+// forward 在当前goroutine中运行generate，使其panic由fx流转换为PanicError，
+// generate产生的元素由单独的goroutine转发到source，ctx取消后停止转发
+func forward[T any](ctx context.Context, generate func(source chan<- T), source chan<- any) {
+	pipe := make(chan T)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for item := range pipe {
+			select {
+			case source <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(pipe)
+		<-done
+	}()
+
+	generate(pipe)
+}
+
+// wrap 将fx.Stream包装为Stream[T]
+func wrap[T any](ctx context.Context, stream fx.Stream) Stream[T] {
+	return Stream[T]{
+		stream: stream,
+		ctx:    ctx,
+	}
+}
@@ -0,0 +1,116 @@
+package typed
+
+import (
+	"context"
+	"errors"
+	"just4play/util/fx"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMapReduce(t *testing.T) {
+	result, err := Reduce(Map(Just(1, 2, 3, 4, 5), func(item int) string {
+		return strconv.Itoa(item * item)
+	}, fx.WithWorkers(2)).Filter(func(item string) bool {
+		return len(item) == 1
+	}), 0, func(acc int, item string) int {
+		val, _ := strconv.Atoi(item)
+		return acc + val
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 14 {
+		t.Fatalf("expect 14, got %d", result)
+	}
+}
+
+func TestSortHeadTail(t *testing.T) {
+	var items []int
+	err := From(func(source chan<- int) {
+		for _, v := range []int{5, 3, 1, 4, 2} {
+			source <- v
+		}
+	}).Sort(func(a, b int) bool {
+		return a < b
+	}).Head(4).Tail(2).Reverse().ForEach(func(item int) {
+		items = append(items, item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0] != 4 || items[1] != 3 {
+		t.Fatalf("unexpected items %v", items)
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	count, err := Just("Go", "go", "GO", "php").Distinct(func(item string) any {
+		return strings.ToLower(item)
+	}).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expect 2, got %d", count)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var groups [][]string
+	err := Group(Just("golang", "google", "php", "python", "java"), func(item string) byte {
+		return item[0]
+	}).ForEach(func(group []string) {
+		groups = append(groups, group)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+	if len(groups) != 3 || len(groups[0]) != 2 || len(groups[1]) != 1 || len(groups[2]) != 2 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+}
+
+func TestRange(t *testing.T) {
+	ch := make(chan error, 2)
+	ch <- errors.New("a")
+	ch <- nil
+	close(ch)
+	if count, err := Range(ch).Count(); err != nil || count != 2 {
+		t.Fatalf("expect 2, got %d", count)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := FromContext(ctx, func(ctx context.Context, source chan<- int) {
+		for i := 0; i < 10; i++ {
+			select {
+			case source <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}).Done()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+}
+
+func TestFromPanic(t *testing.T) {
+	count, err := From(func(source chan<- int) {
+		source <- 1
+		panic("boom")
+	}).Count()
+	var panicErr *fx.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("expect PanicError, got %d, %v", count, err)
+	}
+}
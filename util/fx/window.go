@@ -0,0 +1,315 @@
+package fx
+
+import "time"
+
+type (
+	// session 是SessionWindow中一个key对应的会话
+	session struct {
+		items []any
+		last  time.Time
+	}
+
+	// timedItem 记录了元素进入窗口的时间
+	timedItem struct {
+		item any
+		at   time.Time
+	}
+)
+
+// Batch 将流中元素按批输出，每批最多size个元素，以[]any写入新流
+// 一批中第一个元素进入后超过maxWait仍未凑满时也会输出，避免上游较慢时一直等待，
+// 流结束时输出剩余元素，可以通过WithClock指定时钟
+func (s Stream) Batch(size int, maxWait time.Duration, opts ...Option) Stream {
+	if size < 1 {
+		panic("size should be greater than 0")
+	}
+	if maxWait <= 0 {
+		panic("maxWait should be greater than 0")
+	}
+
+	clock := s.options(opts...).clock
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+
+		var batch []any
+		var timer Timer
+		// flush 输出当前批次并停止定时器
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer = nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+
+			ok := s.send(source, batch)
+			batch = nil
+			return ok
+		}
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			var expire <-chan time.Time
+			if timer != nil {
+				expire = timer.C()
+			}
+
+			select {
+			case item, ok := <-s.input():
+				if !ok {
+					flush()
+					return
+				}
+				s.observeRecv()
+
+				batch = append(batch, item)
+				if len(batch) == 1 {
+					timer = clock.NewTimer(maxWait)
+				}
+				if len(batch) == size && !flush() {
+					return
+				}
+			case <-expire:
+				if !flush() {
+					return
+				}
+			case <-s.ctx.Done():
+				return
+			case <-s.state.done:
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// CountWindow 按元素个数划分窗口，每个窗口包含size个元素，每隔slide个元素开始一个新窗口，窗口以[]any写入新流
+// slide等于size时为滚动窗口，与Split相同；slide小于size时为滑动窗口，相邻窗口有size-slide个元素重叠
+// 流结束时如有尚未输出过的元素，将剩余元素作为最后一个窗口输出
+func (s Stream) CountWindow(size, slide int) Stream {
+	if size < 1 {
+		panic("size should be greater than 0")
+	}
+	if slide < 1 || slide > size {
+		panic("slide should be in range [1, size]")
+	}
+
+	source := make(chan any)
+	go func() {
+		defer close(source)
+		defer s.release()
+
+		var window []any
+		// 上个窗口输出后新进入的元素个数
+		var fresh int
+		for {
+			item, ok := s.recv()
+			if !ok {
+				break
+			}
+
+			window = append(window, item)
+			fresh++
+			if len(window) == size {
+				if !s.send(source, append([]any(nil), window...)) {
+					return
+				}
+				window = window[slide:]
+				fresh = 0
+			}
+		}
+		if fresh > 0 {
+			s.send(source, window)
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// SessionWindow 按fn返回的key划分会话窗口，同一key超过gap没有新元素时会话结束，
+// 该会话的所有元素以[]any写入新流，流结束时输出所有未结束的会话，
+// 同时结束的会话按开始的先后顺序输出，可以通过WithClock指定时钟
+func (s Stream) SessionWindow(fn KeyFunc, gap time.Duration, opts ...Option) Stream {
+	if gap <= 0 {
+		panic("gap should be greater than 0")
+	}
+
+	clock := s.options(opts...).clock
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+		defer s.state.recover()
+
+		sessions := make(map[any]*session)
+		// keys 按会话开始的先后顺序记录未结束的会话，使输出顺序确定
+		var keys []any
+		var timer Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			var expire <-chan time.Time
+			if timer != nil {
+				expire = timer.C()
+			}
+
+			select {
+			case item, ok := <-s.input():
+				if !ok {
+					for _, key := range keys {
+						if !s.send(source, sessions[key].items) {
+							return
+						}
+					}
+					return
+				}
+				s.observeRecv()
+
+				key := fn(item)
+				sess, ok := sessions[key]
+				if !ok {
+					sess = new(session)
+					sessions[key] = sess
+					keys = append(keys, key)
+				}
+				sess.items = append(sess.items, item)
+				sess.last = clock.Now()
+				// 新元素的超时时间不会早于已有会话，只需在没有定时器时创建
+				if timer == nil {
+					timer = clock.NewTimer(gap)
+				}
+			case <-expire:
+				now := clock.Now()
+				var next time.Time
+				remain := keys[:0]
+				for _, key := range keys {
+					sess := sessions[key]
+					if now.Sub(sess.last) >= gap {
+						if !s.send(source, sess.items) {
+							return
+						}
+						delete(sessions, key)
+						continue
+					}
+
+					remain = append(remain, key)
+					if next.IsZero() || sess.last.Before(next) {
+						next = sess.last
+					}
+				}
+				keys = remain
+
+				timer = nil
+				if len(sessions) > 0 {
+					timer = clock.NewTimer(next.Add(gap).Sub(now))
+				}
+			case <-s.ctx.Done():
+				return
+			case <-s.state.done:
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// SlidingWindow 按时间划分滑动窗口，每隔slide输出一次最近size时间内进入的元素，窗口以[]any写入新流
+// 没有元素的窗口不会输出，流结束时如有尚未输出过的元素，将最近size时间内的元素作为最后一个窗口输出，
+// 可以通过WithClock指定时钟
+func (s Stream) SlidingWindow(size, slide time.Duration, opts ...Option) Stream {
+	if size <= 0 {
+		panic("size should be greater than 0")
+	}
+	if slide <= 0 || slide > size {
+		panic("slide should be in range (0, size]")
+	}
+
+	clock := s.options(opts...).clock
+	source := make(chan any)
+
+	go func() {
+		defer close(source)
+		defer s.release()
+
+		var items []timedItem
+		// window 返回在[end-size, end)内进入的元素
+		window := func(end time.Time) []any {
+			var result []any
+			for _, each := range items {
+				if !each.at.Before(end.Add(-size)) && each.at.Before(end) {
+					result = append(result, each.item)
+				}
+			}
+			return result
+		}
+
+		// 窗口边界按计划时间推进，不受定时器触发延迟的影响
+		end := clock.Now().Add(slide)
+		timer := clock.NewTimer(slide)
+		defer func() {
+			// timer会被重新创建，需要在退出时取最新的值
+			timer.Stop()
+		}()
+
+		for {
+			select {
+			case item, ok := <-s.input():
+				if !ok {
+					// 只有上个窗口之后有新元素进入时才输出最后一个窗口
+					if n := len(items); n > 0 && !items[n-1].at.Before(end.Add(-slide)) {
+						s.send(source, window(clock.Now().Add(time.Nanosecond)))
+					}
+					return
+				}
+				s.observeRecv()
+
+				items = append(items, timedItem{
+					item: item,
+					at:   clock.Now(),
+				})
+			case <-timer.C():
+				if result := window(end); len(result) > 0 {
+					if !s.send(source, result) {
+						return
+					}
+				}
+
+				// 淘汰不会出现在下一个窗口中的元素
+				end = end.Add(slide)
+				var i int
+				for i < len(items) && items[i].at.Before(end.Add(-size)) {
+					i++
+				}
+				items = items[i:]
+				timer = clock.NewTimer(end.Sub(clock.Now()))
+			case <-s.ctx.Done():
+				return
+			case <-s.state.done:
+				return
+			}
+		}
+	}()
+
+	return s.derive(source)
+}
+
+// TimeWindow 按时间划分滚动窗口，每隔size输出一次这段时间内进入的元素，窗口以[]any写入新流，
+// 与slide等于size的SlidingWindow相同
+func (s Stream) TimeWindow(size time.Duration, opts ...Option) Stream {
+	return s.SlidingWindow(size, size, opts...)
+}
@@ -0,0 +1,281 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	clock := newSyncClock()
+	items := make(chan any)
+	out := consume(Range(items).Batch(2, time.Millisecond*50, WithClock(clock)))
+
+	// 每批的第一个元素进入时创建定时器
+	for i := 1; i <= 5; i++ {
+		items <- i
+	}
+	clock.await(3)
+	// 上游变慢时不凑满也会输出
+	clock.Advance(time.Millisecond * 50)
+	batches := [][]any{(<-out).([]any), (<-out).([]any), (<-out).([]any)}
+
+	items <- 6
+	close(items)
+	for item := range out {
+		batches = append(batches, item.([]any))
+	}
+
+	expect := [][]any{{1, 2}, {3, 4}, {5}, {6}}
+	if !reflect.DeepEqual(batches, expect) {
+		t.Fatalf("expect %v, got %v", expect, batches)
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err := FromContext(ctx, func(ctx context.Context, source chan<- any) {
+		select {
+		case source <- 1:
+		case <-ctx.Done():
+			return
+		}
+		<-ctx.Done()
+	}).Batch(10, time.Hour).Done()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestCountWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		slide  int
+		expect [][]any
+	}{
+		{
+			name:   "tumbling",
+			size:   2,
+			slide:  2,
+			expect: [][]any{{1, 2}, {3, 4}, {5}},
+		},
+		{
+			name:   "sliding",
+			size:   3,
+			slide:  1,
+			expect: [][]any{{1, 2, 3}, {2, 3, 4}, {3, 4, 5}},
+		},
+		{
+			name:   "sliding with tail",
+			size:   3,
+			slide:  2,
+			expect: [][]any{{1, 2, 3}, {3, 4, 5}},
+		},
+		{
+			name:   "not enough items",
+			size:   10,
+			slide:  5,
+			expect: [][]any{{1, 2, 3, 4, 5}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var windows [][]any
+			err := Just(1, 2, 3, 4, 5).CountWindow(test.size, test.slide).ForEach(func(item any) {
+				windows = append(windows, item.([]any))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(windows, test.expect) {
+				t.Fatalf("expect %v, got %v", test.expect, windows)
+			}
+		})
+	}
+}
+
+// syncClock 包装ManualClock，每次调用Now或NewTimer后通知测试，
+// 测试据此确认被测操作已处理完元素后再推进时间
+type syncClock struct {
+	*ManualClock
+	calls chan struct{}
+}
+
+func newSyncClock() syncClock {
+	return syncClock{
+		ManualClock: NewManualClock(time.Unix(0, 0)),
+		calls:       make(chan struct{}, 64),
+	}
+}
+
+func (c syncClock) Now() time.Time {
+	now := c.ManualClock.Now()
+	c.calls <- struct{}{}
+	return now
+}
+
+func (c syncClock) NewTimer(d time.Duration) Timer {
+	timer := c.ManualClock.NewTimer(d)
+	c.calls <- struct{}{}
+	return timer
+}
+
+// await 等待被测操作调用n次Now或NewTimer
+func (c syncClock) await(n int) {
+	for i := 0; i < n; i++ {
+		<-c.calls
+	}
+}
+
+// consume 在后台消费流，返回依次取得流中元素的channel，流结束后关闭
+func consume(s Stream) <-chan any {
+	out := make(chan any, 16)
+	go func() {
+		defer close(out)
+		s.ToChannel(out)
+	}()
+
+	return out
+}
+
+func TestTimeWindow(t *testing.T) {
+	clock := newSyncClock()
+	items := make(chan any)
+	out := consume(Range(items).TimeWindow(time.Millisecond*100, WithClock(clock)))
+
+	// 窗口开始时读取时间并创建定时器，之后每个元素进入时读取一次时间
+	clock.await(2)
+	items <- 1
+	items <- 2
+	items <- 3
+	clock.await(3)
+	clock.Advance(time.Millisecond * 100)
+	windows := [][]any{(<-out).([]any)}
+
+	// 输出窗口后读取时间创建下一个定时器
+	clock.await(2)
+	items <- 4
+	items <- 5
+	clock.await(2)
+	clock.Advance(time.Millisecond * 100)
+	windows = append(windows, (<-out).([]any))
+	close(items)
+	for item := range out {
+		windows = append(windows, item.([]any))
+	}
+
+	expect := [][]any{{1, 2, 3}, {4, 5}}
+	if !reflect.DeepEqual(windows, expect) {
+		t.Fatalf("expect %v, got %v", expect, windows)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newSyncClock()
+	items := make(chan any)
+	out := consume(Range(items).SlidingWindow(time.Millisecond*200, time.Millisecond*100, WithClock(clock)))
+
+	clock.await(2)
+	items <- 1
+	clock.await(1)
+	clock.Advance(time.Millisecond * 100)
+	windows := [][]any{(<-out).([]any)}
+
+	clock.await(2)
+	clock.Advance(time.Millisecond * 50)
+	items <- 2
+	clock.await(1)
+	clock.Advance(time.Millisecond * 50)
+	windows = append(windows, (<-out).([]any))
+	close(items)
+	for item := range out {
+		windows = append(windows, item.([]any))
+	}
+
+	// 100ms: [1], 200ms: [1 2], 结束时上个窗口之后没有新元素进入，不再重复输出
+	expect := [][]any{{1}, {1, 2}}
+	if !reflect.DeepEqual(windows, expect) {
+		t.Fatalf("expect %v, got %v", expect, windows)
+	}
+}
+
+func TestSessionWindow(t *testing.T) {
+	clock := newSyncClock()
+	items := make(chan any)
+	out := consume(Range(items).SessionWindow(func(item any) any {
+		return item.(string)[0]
+	}, time.Millisecond*100, WithClock(clock)))
+
+	// 每个元素进入时读取一次时间，第一个元素还会创建定时器
+	items <- "a1"
+	items <- "b1"
+	items <- "a2"
+	clock.await(4)
+	clock.Advance(time.Millisecond * 150)
+	results := []any{<-out, <-out}
+
+	items <- "a3"
+	close(items)
+	for item := range out {
+		results = append(results, item)
+	}
+
+	var windows []string
+	for _, item := range results {
+		var window string
+		for _, each := range item.([]any) {
+			window += each.(string)
+		}
+		windows = append(windows, window)
+	}
+	sort.Strings(windows)
+	expect := []string{"a1a2", "a3", "b1"}
+	if !reflect.DeepEqual(windows, expect) {
+		t.Fatalf("expect %v, got %v", expect, windows)
+	}
+}
+
+func TestSessionWindowOrder(t *testing.T) {
+	keys := []string{"e", "c", "a", "d", "b"}
+	for i := 0; i < 10; i++ {
+		var items []any
+		for _, key := range keys {
+			items = append(items, key+"1", key+"2")
+		}
+
+		// 流结束时未结束的会话按开始的先后顺序输出
+		windows, err := Just(items...).SessionWindow(func(item any) any {
+			return item.(string)[0]
+		}, time.Hour).ToSlice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, window := range windows {
+			expect := []any{keys[j] + "1", keys[j] + "2"}
+			if !reflect.DeepEqual(window, expect) {
+				t.Fatalf("expect %v at %d, got %v", expect, j, window)
+			}
+		}
+		if len(windows) != len(keys) {
+			t.Fatalf("expect %d windows, got %d", len(keys), len(windows))
+		}
+	}
+}
+
+func TestSessionWindowPanic(t *testing.T) {
+	_, err := Just(1, 2, 3).SessionWindow(func(item any) any {
+		panic("boom")
+	}, time.Second).Count()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expect PanicError, got %v", err)
+	}
+}
@@ -1,12 +1,18 @@
 package mp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
 )
 
+const (
+	defaultWorkers = 16
+	minWorkers     = 1
+)
+
 //实际业务场景中多个依赖如果有一个出错我们期望能立即返回而不是等所有依赖都执行完再返回结果
 
 var (
@@ -31,6 +37,14 @@ type (
 	Writer interface {
 		Writer(val interface{})
 	}
+
+	// Option 用于自定义MapReduce的选项
+	Option func(opts *mapReduceOptions)
+
+	mapReduceOptions struct {
+		ctx     context.Context //ctx取消后MapReduce以ctx.Err()结束
+		workers int             //mapper的最大并发数
+	}
 )
 
 type writeChan struct {
@@ -76,8 +90,8 @@ func buildSource(generate GenerateFunc) chan interface{} {
 	return source
 }
 
-// 消费generate产生的数据，并写入collector，mapper默认最大并发数为16
-func executeMappers(mapper MapFunc, collector chan interface{}, done chan struct{}, source <-chan interface{}) {
+// 消费generate产生的数据，并发执行mapper写入collector，最多同时有workers个mapper在执行
+func executeMappers(mapper MapFunc, collector chan interface{}, done chan struct{}, source <-chan interface{}, workers int) {
 	var wg sync.WaitGroup
 	defer func() {
 		wg.Wait()
@@ -86,13 +100,20 @@ func executeMappers(mapper MapFunc, collector chan interface{}, done chan struct
 	}()
 
 	writer := newWriteChan(collector, done)
-	pool := make(chan struct{}, 16)
+	pool := make(chan struct{}, workers)
 	for {
 		select {
 		case <-done:
 			return
 		case pool <- struct{}{}:
-			item, ok := <-source
+			var item interface{}
+			var ok bool
+			select {
+			case <-done:
+				<-pool
+				return
+			case item, ok = <-source:
+			}
 			if !ok {
 				//说明管道已关闭
 				<-pool
@@ -103,18 +124,20 @@ func executeMappers(mapper MapFunc, collector chan interface{}, done chan struct
 			go func() {
 				defer func() {
 					wg.Done()
-					// 在这里关闭, 以保证最多有 16 个在进行
+					// 在这里释放, 以保证最多有 workers 个在进行
 					<-pool
 				}()
+				//运行自定义处理函数
+				mapper(item, writer)
 			}()
-			//运行自定义处理函数
-			mapper(item, writer)
 		}
 	}
 }
 
-// MapReduce 并发执行任务
-func MapReduce(generate GenerateFunc, mapper MapperFunc, reducer ReducerFunc) (interface{}, error) {
+// MapReduce 并发执行任务，可以通过WithWorkers设置mapper的最大并发数，默认为16，
+// 通过WithContext设置的ctx取消后立即结束并返回ctx.Err()
+func MapReduce(generate GenerateFunc, mapper MapperFunc, reducer ReducerFunc, opts ...Option) (interface{}, error) {
+	options := buildOptions(opts...)
 	source := buildSource(generate)
 	var errVal atomic.Value
 	done := make(chan struct{})
@@ -146,6 +169,15 @@ func MapReduce(generate GenerateFunc, mapper MapperFunc, reducer ReducerFunc) (i
 
 		finish()
 	}
+	// ctx取消时和其他错误一样通知所有goroutine退出
+	go func() {
+		select {
+		case <-options.ctx.Done():
+			cancel(options.ctx.Err())
+		case <-done:
+		}
+	}()
+
 	write := newWriteChan(reduceChan, done)
 	// 存放处理结果, mapper 往这个里面写入， reduce 从这个里面读出
 	resChan := make(chan interface{})
@@ -161,7 +193,7 @@ func MapReduce(generate GenerateFunc, mapper MapperFunc, reducer ReducerFunc) (i
 	// 现在开始从执行管道里读取数据处理
 	go executeMappers(func(item interface{}, writer Writer) {
 		mapper(item, writer, cancel)
-	}, resChan, done, source)
+	}, resChan, done, source, options.workers)
 
 	// 此时我们应该取出错误 和 结果
 	res, ok := <-reduceChan
@@ -199,3 +231,37 @@ func Finish(fns ...func() error) error {
 	})
 	return err
 }
+
+// WithContext 设置MapReduce的ctx，ctx取消后MapReduce立即结束并返回ctx.Err()
+func WithContext(ctx context.Context) Option {
+	return func(opts *mapReduceOptions) {
+		opts.ctx = ctx
+	}
+}
+
+// WithWorkers 设置mapper的最大并发数，最小为1
+func WithWorkers(workers int) Option {
+	return func(opts *mapReduceOptions) {
+		if workers < minWorkers {
+			opts.workers = minWorkers
+		} else {
+			opts.workers = workers
+		}
+	}
+}
+
+func buildOptions(opts ...Option) *mapReduceOptions {
+	options := newOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options
+}
+
+func newOptions() *mapReduceOptions {
+	return &mapReduceOptions{
+		ctx:     context.Background(),
+		workers: defaultWorkers,
+	}
+}
@@ -1,9 +1,11 @@
 package mp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -68,3 +70,75 @@ func TestFinish(t *testing.T) {
 
 	t.Log("finish err:", err)
 }
+
+func TestMapReduceConcurrent(t *testing.T) {
+	var running, peak int32
+	generate := func(source chan<- interface{}) {
+		for i := 0; i < 8; i++ {
+			source <- i
+		}
+	}
+	mapper := func(item interface{}, writer Writer, cancel func(err error)) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		writer.Writer(item)
+	}
+	reducer := func(pipe <-chan interface{}, writer Writer, cancel func(err error)) {
+		var count int
+		for range pipe {
+			count++
+		}
+		writer.Writer(count)
+	}
+
+	start := time.Now()
+	res, err := MapReduce(generate, mapper, reducer, WithWorkers(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != 8 {
+		t.Fatalf("expect 8, got %v", res)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("expect mappers to run concurrently, took %v", elapsed)
+	}
+
+	peak = 0
+	if _, err = MapReduce(generate, mapper, reducer, WithWorkers(2)); err != nil {
+		t.Fatal(err)
+	}
+	if peak != 2 {
+		t.Fatalf("expect at most 2 concurrent mappers, got %d", peak)
+	}
+}
+
+func TestMapReduceWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := MapReduce(func(source chan<- interface{}) {
+		source <- 1
+	}, func(item interface{}, writer Writer, cancel func(err error)) {
+		time.Sleep(time.Second)
+		writer.Writer(item)
+	}, func(pipe <-chan interface{}, writer Writer, cancel func(err error)) {
+		for item := range pipe {
+			writer.Writer(item)
+		}
+	}, WithContext(ctx))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expect to return on ctx timeout, took %v", elapsed)
+	}
+}